@@ -0,0 +1,290 @@
+// Package cli implements the configreader command line tool, which inspects
+// the config files of a config struct without writing Go
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/go-srv/configreader"
+	"github.com/spf13/cobra"
+)
+
+// Main runs the command line tool for the config struct, e.g. in a generated main
+//
+//	func main() { cli.Main(&Config{}) }
+func Main(confPtr interface{}) {
+	typ := reflect.TypeOf(confPtr).Elem()
+	cmd := NewCommand(func() (interface{}, error) {
+		return reflect.New(typ).Interface(), nil
+	})
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// NewCommand creates the command line tool, newConf returns the pointer to a new config struct
+func NewCommand(newConf func() (interface{}, error)) *cobra.Command {
+	o := &cmdOptions{newConf: newConf}
+
+	root := &cobra.Command{
+		Use:          "configreader",
+		Short:        "Inspect the config files of a config struct",
+		SilenceUsage: true,
+	}
+	fs := root.PersistentFlags()
+	fs.StringVarP(&o.configFile, "config", "c", "", "the base config file, the overlays next to it are merged")
+	fs.StringVar(&o.configEnv, "env", "", "the env suffix of the overlay to merge, e.g. prod for config_prod.yaml")
+	fs.StringVar(&o.envPrefix, "env-prefix", "APP", "the prefix of the env vars")
+	fs.BoolVar(&o.showSecrets, "show-secrets", false, "show the values of the secrets, which are masked by default")
+	fs.StringVar(&o.keyFile, "key-file", "", "the file of the base64 encoded key to decrypt and encrypt the ENC[...] values")
+
+	root.AddCommand(
+		o.validateCommand(),
+		o.dumpCommand(),
+		o.explainCommand(),
+		o.diffCommand(),
+		o.schemaCommand(),
+		o.encryptCommand(),
+	)
+	return root
+}
+
+type cmdOptions struct {
+	newConf     func() (interface{}, error)
+	configFile  string
+	configEnv   string
+	envPrefix   string
+	showSecrets bool
+	keyFile     string
+}
+
+// dumpOptions are the options of dumping configs by the flags
+func (o *cmdOptions) dumpOptions() []configreader.Option {
+	if o.showSecrets {
+		return []configreader.Option{configreader.WithUnmaskedSecrets()}
+	}
+	return nil
+}
+
+// provenance unmasks the secret of the provenance if --show-secrets
+func (o *cmdOptions) provenance(p *configreader.KeyProvenance) *configreader.KeyProvenance {
+	if o.showSecrets {
+		p.Secret = false
+	}
+	return p
+}
+
+func (o *cmdOptions) newReader(configEnv string) (*configreader.ConfigReader, error) {
+	if o.configFile == "" {
+		return nil, fmt.Errorf("--config is required")
+	}
+
+	r := configreader.New()
+	r.SetEnvPrefix(o.envPrefix)
+	r.SetConfigEnv(configEnv)
+	r.SetConfigPaths([]string{filepath.Dir(o.configFile)})
+	r.SetConfigName(strings.TrimSuffix(filepath.Base(o.configFile), filepath.Ext(o.configFile)))
+	if o.keyFile != "" {
+		r.SetDecryptionKeyFile(o.keyFile)
+	}
+	return r, nil
+}
+
+// load loads the config files with the env vars, but without the flags of the process
+func (o *cmdOptions) load(configEnv string) (*configreader.ConfigReader, interface{}, error) {
+	r, err := o.newReader(configEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	confPtr, err := o.newConf()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = r.LoadConfig(confPtr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, confPtr, nil
+}
+
+func (o *cmdOptions) validateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the config files against the config struct, env vars are not applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, err := o.newReader(o.configEnv)
+			if err != nil {
+				return err
+			}
+			confPtr, err := o.newConf()
+			if err != nil {
+				return err
+			}
+
+			issues := r.ValidateFile(o.configFile, confPtr)
+			for _, issue := range issues {
+				fmt.Fprintln(cmd.OutOrStdout(), issue)
+			}
+			if len(issues) > 0 {
+				return fmt.Errorf("%d issues found", len(issues))
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is valid\n", o.configFile)
+			return nil
+		},
+	}
+}
+
+func (o *cmdOptions) dumpCommand() *cobra.Command {
+	var format, output string
+	var overwrite, provenance bool
+
+	cmd := &cobra.Command{
+		Use:   "dump",
+		Short: "Dump the resolved config",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, confPtr, err := o.load(o.configEnv)
+			if err != nil {
+				return err
+			}
+
+			opts := o.dumpOptions()
+			if output != "" {
+				opts = append(opts, configreader.WithAtomicWrite())
+				if overwrite {
+					opts = append(opts, configreader.WithOverwrite())
+				}
+				return r.DumpConfig(output, confPtr, opts...)
+			}
+			if provenance {
+				return r.DumpEffective(cmd.OutOrStdout(), format, append(opts, configreader.WithProvenance())...)
+			}
+			return r.DumpConfigTo(cmd.OutOrStdout(), format, confPtr, opts...)
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "yaml", "the format to print, one of "+strings.Join(configreader.SupportedExts, ", "))
+	cmd.Flags().StringVarP(&output, "output", "o", "", "the file to dump into, the format is told by the extension")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "overwrite the output file if it exists")
+	cmd.Flags().BoolVar(&provenance, "provenance", false, "comment every key with where its value comes from")
+	return cmd
+}
+
+func (o *cmdOptions) explainCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain [key]",
+		Short: "Explain where the value of the key comes from, all keys if no key is given",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, _, err := o.load(o.configEnv)
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				for _, p := range r.Explain() {
+					fmt.Fprintln(cmd.OutOrStdout(), o.provenance(p))
+				}
+				return nil
+			}
+
+			p := r.Provenance(args[0])
+			if p == nil {
+				return fmt.Errorf("unknown key [%s]", args[0])
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), o.provenance(p))
+			return nil
+		},
+	}
+}
+
+func (o *cmdOptions) diffCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <envA> <envB>",
+		Short: "Show the keys which differ between the configs of two envs",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			readerA, confA, err := o.load(args[0])
+			if err != nil {
+				return fmt.Errorf("%s: %w", args[0], err)
+			}
+			readerB, confB, err := o.load(args[1])
+			if err != nil {
+				return fmt.Errorf("%s: %w", args[1], err)
+			}
+
+			for _, key := range configreader.ChangedKeys(confA, confB) {
+				pA, pB := o.provenance(readerA.Provenance(key)), o.provenance(readerB.Provenance(key))
+				valueA, valueB := pA.Value, pB.Value
+				// the value is a secret in one env when it's encrypted or read from a file there only
+				if pA.Secret || pB.Secret {
+					valueA, valueB = configreader.SecretMask, configreader.SecretMask
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: %v -> %v\n", key, valueA, valueB)
+			}
+			return nil
+		},
+	}
+}
+
+func (o *cmdOptions) schemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the config struct",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			confPtr, err := o.newConf()
+			if err != nil {
+				return err
+			}
+
+			data, err := configreader.GenerateJSONSchema(confPtr)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return nil
+		},
+	}
+}
+
+func (o *cmdOptions) encryptCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "encrypt [value]",
+		Short: "Encrypt the value into ENC[...] for the config files, the value is read from stdin if it's not given",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if o.keyFile == "" {
+				return fmt.Errorf("--key-file is required")
+			}
+
+			var value string
+			if len(args) > 0 {
+				value = args[0]
+			} else {
+				data, err := ioutil.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				value = strings.TrimRight(string(data), "\r\n")
+			}
+
+			r := configreader.New()
+			r.SetDecryptionKeyFile(o.keyFile)
+			encrypted, err := r.Encrypt(value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), encrypted)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Conf struct {
+	Host string `key:"host" required:"true"`
+	Port int    `key:"port" default:"80" validation:"range:[1,65535]"`
+}
+
+func run(t *testing.T, args ...string) (string, error) {
+	cmd := NewCommand(func() (interface{}, error) { return &Conf{}, nil })
+	out := new(bytes.Buffer)
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configreader")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, data := range map[string]string{
+		"config.yaml":         "host: base.local\n",
+		"config_staging.yaml": "port: 8080\n",
+		"config_prod.yaml":    "host: prod.local\nport: 0\nhots: x\n",
+	} {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		assert.Nil(t, err)
+	}
+	configFile := filepath.Join(dir, "config.yaml")
+
+	out, err := run(t, "validate", "-c", configFile, "--env", "staging")
+	assert.Nil(t, err)
+	assert.Equal(t, configFile+" is valid\n", out)
+
+	out, err = run(t, "validate", "-c", configFile, "--env", "prod")
+	assert.EqualError(t, err, "2 issues found")
+	prodFile := filepath.Join(dir, "config_prod.yaml")
+	assert.Contains(t, out, prodFile+":3:1: unknown config key [hots], did you mean [host]?\n")
+	assert.Contains(t, out, prodFile+":2:1: [port] did not pass validation [range:[1,65535]]. real [0]\n")
+
+	out, err = run(t, "explain", "port", "-c", configFile, "--env", "staging")
+	assert.Nil(t, err)
+	assert.Equal(t, "port = 8080 from file "+filepath.Join(dir, "config_staging.yaml")+":1:1, shadows 80 from default\n", out)
+
+	_, err = run(t, "explain", "nokey", "-c", configFile)
+	assert.EqualError(t, err, "unknown key [nokey]")
+
+	out, err = run(t, "diff", "dev", "staging", "-c", configFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "port: 80 -> 8080\n", out)
+
+	out, err = run(t, "dump", "-f", "json", "-c", configFile, "--env", "staging")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"host": "base.local", "port": 8080}`, out)
+
+	out, err = run(t, "dump", "--provenance", "-c", configFile, "--env", "staging")
+	assert.Nil(t, err)
+	assert.Equal(t, "# from file "+configFile+":1:1\nhost: base.local\n"+
+		"# from file "+filepath.Join(dir, "config_staging.yaml")+":1:1\nport: 8080\n", out)
+
+	dumpFile := filepath.Join(dir, "dump.json")
+	_, err = run(t, "dump", "-o", dumpFile, "-c", configFile)
+	assert.Nil(t, err)
+	_, err = run(t, "dump", "-o", dumpFile, "-c", configFile, "--env", "staging")
+	assert.True(t, os.IsExist(err))
+	_, err = run(t, "dump", "-o", dumpFile, "-c", configFile, "--env", "staging", "--overwrite")
+	assert.Nil(t, err)
+	data, err := ioutil.ReadFile(dumpFile)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"host": "base.local", "port": 8080}`, string(data))
+
+	keyFile := filepath.Join(dir, "key")
+	err = ioutil.WriteFile(keyFile, []byte("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"), 0600)
+	assert.Nil(t, err)
+	_, err = run(t, "encrypt", "enc.local")
+	assert.EqualError(t, err, "--key-file is required")
+	out, err = run(t, "encrypt", "enc.local", "--key-file", keyFile)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(out, "ENC["))
+	err = ioutil.WriteFile(filepath.Join(dir, "config_enc.yaml"), []byte("host: "+out), 0644)
+	assert.Nil(t, err)
+	out, err = run(t, "dump", "-f", "json", "-c", configFile, "--env", "enc", "--key-file", keyFile)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"host": "******", "port": 80}`, out)
+	out, err = run(t, "dump", "-f", "json", "-c", configFile, "--env", "enc", "--key-file", keyFile, "--show-secrets")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"host": "enc.local", "port": 80}`, out)
+	_, err = run(t, "dump", "-c", configFile, "--env", "enc")
+	assert.Contains(t, err.Error(), "[host] unable to decrypt the value")
+	out, err = run(t, "diff", "dev", "enc", "-c", configFile, "--key-file", keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "host: ****** -> ******\n", out)
+	out, err = run(t, "diff", "enc", "dev", "-c", configFile, "--key-file", keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "host: ****** -> ******\n", out)
+	out, err = run(t, "diff", "dev", "enc", "-c", configFile, "--key-file", keyFile, "--show-secrets")
+	assert.Nil(t, err)
+	assert.Equal(t, "host: base.local -> enc.local\n", out)
+	out, err = run(t, "validate", "-c", configFile, "--env", "enc", "--key-file", keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, configFile+" is valid\n", out)
+	out, err = run(t, "validate", "-c", configFile, "--env", "enc")
+	assert.EqualError(t, err, "1 issues found")
+	assert.Contains(t, out, "[host] unable to decrypt the value")
+
+	out, err = run(t, "schema")
+	assert.Nil(t, err)
+	assert.Contains(t, out, `"required": [`)
+
+	_, err = run(t, "validate")
+	assert.EqualError(t, err, "--config is required")
+}
@@ -0,0 +1,36 @@
+// Command configreader inspects the config files of a config struct described by
+// the JSON Schema generated by configreader.GenerateJSONSchema, e.g.
+//
+//	configreader --schema config.schema.json --config /etc/app/config.yaml --env prod validate
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-srv/configreader"
+	"github.com/go-srv/configreader/cli"
+)
+
+func main() {
+	var schemaFile string
+
+	cmd := cli.NewCommand(func() (interface{}, error) {
+		if schemaFile == "" {
+			return nil, fmt.Errorf("--schema is required")
+		}
+
+		data, err := ioutil.ReadFile(schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		return configreader.StructFromJSONSchema(data)
+	})
+	cmd.PersistentFlags().StringVar(&schemaFile, "schema", os.Getenv("CONFIGREADER_SCHEMA"),
+		"the JSON Schema of the config struct, generated by configreader")
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,112 @@
+// Package cobra binds config structs to spf13/cobra commands
+package cobra
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-srv/configreader"
+	spfcobra "github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ConfigFlag is the name of the flag to set the config file
+const ConfigFlag = "config"
+
+type contextKey struct{}
+
+// Reader is the part of configreader.ConfigReader used to bind the commands
+type Reader interface {
+	RegisterFlags(fs *pflag.FlagSet, confPtr interface{}) error
+	SetFlagSet(fs *pflag.FlagSet)
+	SetConfigPaths(paths []string)
+	SetConfigName(configName string)
+	LoadConfig(confPtr interface{}) error
+}
+
+// globalReader wraps the global ConfigReader instance
+type globalReader struct{}
+
+func (globalReader) RegisterFlags(fs *pflag.FlagSet, confPtr interface{}) error {
+	return configreader.RegisterFlags(fs, confPtr)
+}
+func (globalReader) SetFlagSet(fs *pflag.FlagSet)         { configreader.SetFlagSet(fs) }
+func (globalReader) SetConfigPaths(paths []string)        { configreader.SetConfigPaths(paths) }
+func (globalReader) SetConfigName(configName string)      { configreader.SetConfigName(configName) }
+func (globalReader) LoadConfig(confPtr interface{}) error { return configreader.LoadConfig(confPtr) }
+
+// Bind wraps the global ConfigReader instance
+func Bind(cmd *spfcobra.Command, confPtr interface{}) error {
+	return BindReader(cmd, globalReader{}, confPtr)
+}
+
+// BindReader binds the config struct to the command with the reader.
+// The flags in the tags and the --config flag are registered as persistent flags of cmd,
+// a string --config flag defined by cmd already is reused,
+// and the config is loaded and validated in PersistentPreRunE, then the populated struct is
+// put in the context of the command being executed, see FromContext.
+// The PersistentPreRunE (or PersistentPreRun) set before is called after loading, note that
+// cobra only runs the one closest to the command executed, subcommands should not set their own.
+func BindReader(cmd *spfcobra.Command, r Reader, confPtr interface{}) error {
+	fs := cmd.PersistentFlags()
+
+	// the --config flag defined by the command already, e.g. by the cobra generator, is reused
+	configFlag := fs.Lookup(ConfigFlag)
+	if configFlag != nil && configFlag.Value.Type() != "string" {
+		return fmt.Errorf("flag [--%s] is defined as %s, not a string", ConfigFlag, configFlag.Value.Type())
+	}
+
+	err := r.RegisterFlags(fs, confPtr)
+	if err != nil {
+		return err
+	}
+	r.SetFlagSet(fs)
+
+	if configFlag == nil {
+		if fs.Lookup(ConfigFlag) != nil {
+			return fmt.Errorf("flag [--%s] of the config struct conflicts with the flag of the config file", ConfigFlag)
+		}
+		fs.String(ConfigFlag, "", "the config file to load")
+		configFlag = fs.Lookup(ConfigFlag)
+	}
+
+	preRunE, preRun := cmd.PersistentPreRunE, cmd.PersistentPreRun
+	cmd.PersistentPreRun = nil
+	cmd.PersistentPreRunE = func(c *spfcobra.Command, args []string) error {
+		// the folder of --config is the only path to search, so that it's not shadowed by the working directory
+		if configFile := configFlag.Value.String(); configFile != "" {
+			r.SetConfigPaths([]string{filepath.Dir(configFile)})
+			r.SetConfigName(strings.TrimSuffix(filepath.Base(configFile), filepath.Ext(configFile)))
+		}
+
+		err := r.LoadConfig(confPtr)
+		if err != nil {
+			return err
+		}
+
+		ctx := c.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		c.SetContext(context.WithValue(ctx, contextKey{}, confPtr))
+
+		if preRunE != nil {
+			return preRunE(c, args)
+		}
+		if preRun != nil {
+			preRun(c, args)
+		}
+		return nil
+	}
+	return nil
+}
+
+// FromContext returns the config struct pointer put in the context of the command, nil if not found
+func FromContext(ctx context.Context) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Value(contextKey{})
+}
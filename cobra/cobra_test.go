@@ -0,0 +1,106 @@
+package cobra
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-srv/configreader"
+	"github.com/spf13/afero"
+	spfcobra "github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type Conf struct {
+	Host string `flag:"host" default:"localhost" usage:"the host to listen"`
+	Port int    `flag:"port" validation:"range:[1,65535]"`
+}
+
+func newCommand(t *testing.T, got **Conf) *spfcobra.Command {
+	fs := afero.NewMemMapFs()
+	assert.Nil(t, afero.WriteFile(fs, "/etc/app/app.yaml", []byte("port: 80\n"), 0644))
+	// the one in the working directory must not be loaded instead of --config
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, afero.WriteFile(fs, filepath.Join(wd, "app.yaml"), []byte("port: 8080\n"), 0644))
+
+	r := configreader.New()
+	r.SetFs(fs)
+
+	preRun := false
+	root := &spfcobra.Command{
+		Use:              "app",
+		PersistentPreRun: func(cmd *spfcobra.Command, args []string) { preRun = true },
+	}
+	serve := &spfcobra.Command{
+		Use: "serve",
+		RunE: func(cmd *spfcobra.Command, args []string) error {
+			assert.True(t, preRun)
+			*got, _ = FromContext(cmd.Context()).(*Conf)
+			return nil
+		},
+	}
+	root.AddCommand(serve)
+	root.SilenceUsage = true
+	root.SilenceErrors = true
+
+	conf := Conf{}
+	assert.Nil(t, BindReader(root, r, &conf))
+	return root
+}
+
+func TestBind(t *testing.T) {
+	var got *Conf
+	cmd := newCommand(t, &got)
+	assert.Equal(t, "the host to listen", cmd.PersistentFlags().Lookup("host").Usage)
+
+	cmd.SetArgs([]string{"serve", "--config=/etc/app/app.yaml", "--host=0.0.0.0"})
+	err := cmd.ExecuteContext(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, &Conf{Host: "0.0.0.0", Port: 80}, got)
+
+	got = nil
+	cmd = newCommand(t, &got)
+	cmd.SetArgs([]string{"serve", "--config=/etc/app/app.yaml", "--port=0"})
+	err = cmd.Execute()
+	assert.EqualError(t, err, "[port] did not pass validation [range:[1,65535]]. real [0]")
+	assert.Nil(t, got)
+
+	assert.Nil(t, FromContext(context.Background()))
+}
+
+func TestBindConfigFlag(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.Nil(t, afero.WriteFile(fs, "/etc/app/app.yaml", []byte("port: 80\n"), 0644))
+
+	// the --config flag defined by the command is reused
+	r := configreader.New()
+	r.SetFs(fs)
+	var cfgFile string
+	var got *Conf
+	root := &spfcobra.Command{
+		Use: "app",
+		RunE: func(cmd *spfcobra.Command, args []string) error {
+			got, _ = FromContext(cmd.Context()).(*Conf)
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&cfgFile, ConfigFlag, "", "config file")
+	assert.Nil(t, BindReader(root, r, &Conf{}))
+	root.SetArgs([]string{"--config=/etc/app/app.yaml"})
+	assert.Nil(t, root.Execute())
+	assert.Equal(t, "/etc/app/app.yaml", cfgFile)
+	assert.Equal(t, &Conf{Host: "localhost", Port: 80}, got)
+
+	root = &spfcobra.Command{Use: "app"}
+	root.PersistentFlags().Bool(ConfigFlag, false, "config")
+	err := BindReader(root, configreader.New(), &Conf{})
+	assert.EqualError(t, err, "flag [--config] is defined as bool, not a string")
+
+	type ConfigConf struct {
+		Config string `flag:"config"`
+	}
+	err = BindReader(&spfcobra.Command{Use: "app"}, configreader.New(), &ConfigConf{})
+	assert.EqualError(t, err, "flag [--config] of the config struct conflicts with the flag of the config file")
+}
@@ -6,20 +6,25 @@ import (
 	"fmt"
 	"go/ast"
 	"io"
-	"math"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/magiconair/properties"
 	"github.com/mitchellh/mapstructure"
+	"github.com/pelletier/go-toml"
 	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"golang.org/x/xerrors"
+	"gopkg.in/ini.v1"
 	"gopkg.in/yaml.v2"
 )
 
@@ -30,9 +35,17 @@ const (
 	tagFlag       = "flag"
 	tagEnv        = "env"
 	tagRequired   = "required"
+	tagExcluded   = "excluded"
 	tagValidation = "validation"
+	tagSecret     = "secret"
 	skipKey       = "-"
 
+	// conditions of required and excluded tags, e.g. `required:"if=tls.enabled"`
+	condIf      = "if"
+	condUnless  = "unless"
+	condWith    = "with"
+	condWithout = "without"
+
 	devEnv   = "dev"
 	localEnv = "local"
 )
@@ -43,6 +56,10 @@ var (
 
 	// ErrNotStructPointer is returned when value passed to LoadConfig/ReadConfig is not a pointer to a struct.
 	ErrNotStructPointer = xerrors.New("value passed was not a struct pointer")
+
+	// ErrHelp is returned by ParseFlags and LoadConfig/ReadConfig with SetAutoParseFlags when -h or --help
+	// is in the arguments but no such flag is defined, the usage is already printed to the output of the flagset.
+	ErrHelp = xerrors.New("help requested")
 )
 
 // ConfigReader wraps spf13/viper to read configs
@@ -50,6 +67,14 @@ type ConfigReader struct {
 	viper   *viper.Viper
 	flagset *pflag.FlagSet
 
+	// The flagset is created by the reader rather than set by SetFlagSet,
+	// the flags not found in it are created from the fields
+	ownFlagSet     bool
+	autoParseFlags bool
+
+	// The keys of the fields by the flag names bound to them
+	flagKeys map[string]string
+
 	// config file name and paths to search for
 	configName  string
 	configPaths []string
@@ -63,9 +88,42 @@ type ConfigReader struct {
 	// Suffix to merge and override config files
 	// override sequence: default <- env <- local
 	fileEnvName string
+	configEnv   string
 	allowMerge  bool
+
+	// Custom validators for the validation tag
+	validators map[string]ValidatorFunc
+
+	// The fields of the config struct by their lower case keys
+	fields map[string]reflect.StructField
+
+	// The config files merged in order
+	sources []*configSource
+
+	// Report the keys in config files which have no matching field
+	strict bool
+
+	// Neither env vars nor flags are bound, see ValidateFile
+	isolated bool
+
+	// The lower case keys whose values are read from files or decrypted, masked as secrets
+	secretKeys map[string]bool
+
+	// The lower case keys whose values are in the files not read by the isolated reader, they are not validated
+	unresolvedKeys map[string]bool
+
+	// The key to decrypt the ENC[...] values, or the file holding it
+	decryptionKey     []byte
+	decryptionKeyFile string
+
+	// Settings of watching the config files
+	watchInterval time.Duration
+	onReloadError func(error)
 }
 
+// ValidatorFunc validates the value of a field, value is already converted to the field type
+type ValidatorFunc func(fieldKey string, fieldType reflect.Type, value interface{}) error
+
 var c *ConfigReader
 
 func init() {
@@ -86,6 +144,10 @@ func New() *ConfigReader {
 	c.allowMerge = true
 	c.fileEnvName = "APP_ENV"
 
+	c.validators = make(map[string]ValidatorFunc)
+
+	c.watchInterval = time.Second
+
 	c.viper.SetEnvPrefix(c.envPrefix)
 	c.viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	c.viper.AutomaticEnv()
@@ -200,6 +262,34 @@ func (c *ConfigReader) SetEnvName(env string) {
 	c.fileEnvName = strings.ToUpper(strings.ReplaceAll(env, ".", "_"))
 }
 
+// SetConfigEnv wraps the global ConfigReader instance
+func SetConfigEnv(env string) { c.SetConfigEnv(env) }
+
+// SetConfigEnv sets the env suffix of the config file to merge, e.g. 'prod' for config_prod.yaml,
+// instead of reading it from the env var set by SetEnvName
+func (c *ConfigReader) SetConfigEnv(env string) {
+	c.configEnv = env
+}
+
+// RegisterValidator wraps the global ConfigReader instance
+func RegisterValidator(name string, fn ValidatorFunc) { c.RegisterValidator(name, fn) }
+
+// RegisterValidator registers a custom validator, which is used as `validation:"name"`
+// The builtin actions 'in', 'range' and 'regex' can not be overridden
+func (c *ConfigReader) RegisterValidator(name string, fn ValidatorFunc) {
+	if name != "" && fn != nil {
+		c.validators[name] = fn
+	}
+}
+
+// SetStrict wraps the global ConfigReader instance
+func SetStrict(strict bool) { c.SetStrict(strict) }
+
+// SetStrict reports the keys in config files which have no matching field of the config struct
+func (c *ConfigReader) SetStrict(strict bool) {
+	c.strict = strict
+}
+
 // SetFs wraps the global ConfigReader instance
 func SetFs(fs afero.Fs) { c.SetFs(fs) }
 
@@ -226,23 +316,100 @@ func (c *ConfigReader) SetEnvPrefix(in string) {
 // SetFlagSet set the flagset to lookup
 func SetFlagSet(flag *pflag.FlagSet) { c.SetFlagSet(flag) }
 
-// SetFlagSet set the flagset to lookup
+// SetFlagSet set the flagset to lookup, the flags not found in it are ignored.
+// If it's not set, the reader creates its own flagset, see FlagSet
 func (c *ConfigReader) SetFlagSet(flag *pflag.FlagSet) {
 	c.flagset = flag
+	c.ownFlagSet = false
 }
 
-// Debug print viper values
-func Debug() { c.Debug() }
+// FlagSet wraps the global ConfigReader instance
+func FlagSet() *pflag.FlagSet { return c.FlagSet() }
+
+// FlagSet returns the flagset to lookup. If it's not set by SetFlagSet, the reader creates
+// its own one, which unknown flags are allowed in, and the flags in the flag tags are created
+// in it when loading configs
+func (c *ConfigReader) FlagSet() *pflag.FlagSet {
+	if c.flagset == nil {
+		c.flagset = pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+		c.flagset.ParseErrorsWhitelist.UnknownFlags = true
+		c.ownFlagSet = true
+	}
+	return c.flagset
+}
+
+// SetAutoParseFlags wraps the global ConfigReader instance
+func SetAutoParseFlags(auto bool) { c.SetAutoParseFlags(auto) }
 
-// Debug print viper values
-func (c *ConfigReader) Debug() {
-	c.viper.Debug()
+// SetAutoParseFlags parses the command line arguments into the flagset when loading configs,
+// unless it's parsed already
+func (c *ConfigReader) SetAutoParseFlags(auto bool) {
+	c.autoParseFlags = auto
 }
 
-func PrintConfig(structPtr interface{}) {
+// ParseFlags wraps the global ConfigReader instance
+func ParseFlags(args []string) error { return c.ParseFlags(args) }
+
+// ParseFlags parses the arguments (without the program name) into the flagset,
+// it prints the usage and returns ErrHelp for -h and --help
+func (c *ConfigReader) ParseFlags(args []string) error {
+	err := c.FlagSet().Parse(args)
+	if xerrors.Is(err, pflag.ErrHelp) {
+		// the usage is printed by pflag before returning pflag.ErrHelp
+		return ErrHelp
+	}
+	return err
+}
+
+// Debug wraps the global ConfigReader instance
+func Debug(opts ...Option) { c.Debug(opts...) }
+
+// Debug prints the values of all the keys in viper with the secrets masked,
+// use WithUnmaskedSecrets to print all the layers of viper in plain text
+func (c *ConfigReader) Debug(opts ...Option) {
+	if newOptions(opts).unmasked {
+		c.viper.Debug()
+		return
+	}
+
+	keys := c.viper.AllKeys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := c.viper.Get(key)
+		if value == nil {
+			// the values resolved by a former load are cleared
+			continue
+		}
+		if c.isSecretKey(key) {
+			value = maskValue(value)
+		}
+		fmt.Printf("%s: %v\n", key, value)
+	}
+}
+
+// PrintConfig wraps the global ConfigReader instance
+func PrintConfig(structPtr interface{}, opts ...Option) { c.PrintConfig(structPtr, opts...) }
+
+// PrintConfig prints the values of the config struct,
+// use WithProvenance to annotate where the values come from.
+// The secrets are masked unless WithUnmaskedSecrets.
+func (c *ConfigReader) PrintConfig(structPtr interface{}, opts ...Option) {
+	o := newOptions(opts)
+
 	ref := reflect.ValueOf(structPtr).Elem()
 	_ = walkThroughStruct("", ref, func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
-		fmt.Printf("%s: %v\n", fieldKey, structRef)
+		value := plainValue(structRef, !o.unmasked)
+		if !o.unmasked && c.isSecretField(fieldKey, structField) {
+			value = maskValue(value)
+		}
+		if o.provenance {
+			if p := c.Provenance(fieldKey); p != nil && p.Source != nil {
+				fmt.Printf("%s: %v  # %s\n", fieldKey, value, p.Source)
+				return nil
+			}
+		}
+
+		fmt.Printf("%s: %v\n", fieldKey, value)
 		return nil
 	})
 }
@@ -289,12 +456,7 @@ func (c *ConfigReader) loadConfig(confPtr interface{}) error {
 		return err
 	}
 
-	err = c.checkValues(confPtr)
-	if err != nil {
-		return err
-	}
-
-	return c.populateStructValues(confPtr)
+	return c.checkAndPopulate(confPtr)
 }
 
 func (c *ConfigReader) loadConfigs() error {
@@ -302,28 +464,33 @@ func (c *ConfigReader) loadConfigs() error {
 		c.viper.AddConfigPath(configPath)
 	}
 
+	c.sources = nil
+
 	c.viper.SetConfigName(c.configName)
 	err := c.viper.ReadInConfig()
 	if err != nil {
 		return err
 	}
+	err = c.addConfigSource(c.viper.ConfigFileUsed())
+	if err != nil {
+		return err
+	}
 
 	if c.allowMerge {
-		var configFileNotFoundError viper.ConfigFileNotFoundError
-
-		envSuffix := os.Getenv(c.fileEnvName)
+		envSuffix := c.configEnv
+		if len(envSuffix) <= 0 {
+			envSuffix = os.Getenv(c.fileEnvName)
+		}
 		if len(envSuffix) <= 0 {
 			envSuffix = devEnv
 		}
-		c.viper.SetConfigName(c.configName + "_" + envSuffix)
-		err = c.viper.MergeInConfig()
-		if err != nil && !xerrors.As(err, &configFileNotFoundError) {
+		err = c.mergeInConfig(c.configName + "_" + envSuffix)
+		if err != nil {
 			return err
 		}
 
-		c.viper.SetConfigName(c.configName + "_" + localEnv)
-		err = c.viper.MergeInConfig()
-		if err != nil && !xerrors.As(err, &configFileNotFoundError) {
+		err = c.mergeInConfig(c.configName + "_" + localEnv)
+		if err != nil {
 			return err
 		}
 	}
@@ -331,6 +498,22 @@ func (c *ConfigReader) loadConfigs() error {
 	return nil
 }
 
+// mergeInConfig merges the config file with the name if it exists
+func (c *ConfigReader) mergeInConfig(configName string) error {
+	var configFileNotFoundError viper.ConfigFileNotFoundError
+
+	c.viper.SetConfigName(configName)
+	err := c.viper.MergeInConfig()
+	if err != nil {
+		if xerrors.As(err, &configFileNotFoundError) {
+			return nil
+		}
+		return err
+	}
+
+	return c.addConfigSource(c.viper.ConfigFileUsed())
+}
+
 func (c *ConfigReader) readConfig(in io.Reader, configType string, confPtr interface{}) error {
 	err := checkStructPtr(confPtr)
 	if err != nil {
@@ -342,18 +525,66 @@ func (c *ConfigReader) readConfig(in io.Reader, configType string, confPtr inter
 		return err
 	}
 
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	c.sources = []*configSource{{configType: configType, data: data}}
+
 	c.viper.SetConfigType(configType)
-	err = c.viper.ReadConfig(in)
+	err = c.viper.ReadConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	return c.checkAndPopulate(confPtr)
+}
+
+// checkAndPopulate checks the values, populates them into a copy of the struct and
+// then calls the Validate hooks, all the failures are reported together.
+// The struct is written only if all the checks pass.
+func (c *ConfigReader) checkAndPopulate(confPtr interface{}) error {
+	err := c.resolveValues()
 	if err != nil {
 		return err
 	}
 
+	var errs ValidationErrors
+	if c.strict {
+		unknownErrs, err := c.checkUnknownKeys()
+		if err != nil {
+			return err
+		}
+		errs = append(errs, unknownErrs...)
+	}
+
+	var checkErrs ValidationErrors
 	err = c.checkValues(confPtr)
+	if err != nil && !xerrors.As(err, &checkErrs) {
+		return err
+	}
+	errs = append(errs, checkErrs...)
+
+	ref := reflect.ValueOf(confPtr).Elem()
+	// a deep copy, as the maps in the struct are decoded into instead of replaced
+	scratch := reflect.New(ref.Type())
+	scratch.Elem().Set(deepCopy(ref))
+
+	err = c.populateStructValues(scratch.Interface())
 	if err != nil {
+		if len(errs) > 0 {
+			return errs
+		}
 		return err
 	}
 
-	return c.populateStructValues(confPtr)
+	errs = append(errs, c.validateStructs(scratch.Interface())...)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	ref.Set(scratch.Elem())
+	return nil
 }
 
 func checkStructPtr(confPtr interface{}) error {
@@ -373,18 +604,36 @@ func checkStructPtr(confPtr interface{}) error {
 
 func (c *ConfigReader) parseStructTags(confPtr interface{}) error {
 	ref := reflect.ValueOf(confPtr).Elem()
+	c.fields = make(map[string]reflect.StructField)
+	c.flagKeys = make(map[string]string)
 
-	return walkThroughStruct("", ref, c.parseStructTag)
+	err := walkThroughStruct("", ref, c.parseStructTag)
+	if err != nil {
+		return err
+	}
+
+	if c.autoParseFlags && !c.isolated && len(os.Args) > 0 {
+		if !c.FlagSet().Parsed() {
+			return c.ParseFlags(os.Args[1:])
+		}
+	}
+	return nil
 }
 
 func (c *ConfigReader) parseStructTag(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+	c.fields[strings.ToLower(fieldKey)] = structField
+
 	tag := structField.Tag
 	c.bindDefaultValue(fieldKey, tag.Get(tagDefault))
+	if c.isolated {
+		return nil
+	}
+
 	err := c.bindEnvValue(fieldKey, tag.Get(tagEnv))
 	if err != nil {
 		return err
 	}
-	return c.bindFlagValue(fieldKey, tag.Get(tagFlag), tag.Get(tagDefault))
+	return c.bindFlagValue(fieldKey, structField)
 }
 
 func (c *ConfigReader) bindDefaultValue(fieldkey string, val string) {
@@ -400,378 +649,225 @@ func (c *ConfigReader) bindEnvValue(fieldkey string, envname string) error {
 	return nil
 }
 
-func (c *ConfigReader) bindFlagValue(fieldkey string, flagname string, defval string) error {
-	if flagname != "" {
-		var flag *pflag.Flag
-
-		// Not in the command, try search PFlags
-		if c.flagset == nil {
-			pflag.String(flagname, "", flagname)
-			flag = pflag.Lookup(flagname)
-		} else {
-			flag = c.flagset.Lookup(flagname)
-		}
-
-		// ignore flag if cannot find it
-		if flag != nil {
-			return c.viper.BindPFlag(fieldkey, flag)
-		}
+func (c *ConfigReader) bindFlagValue(fieldkey string, structField reflect.StructField) error {
+	flagname := structField.Tag.Get(tagFlag)
+	if flagname == "" {
+		return nil
 	}
-	return nil
-}
-
-////////// Check Values
-
-func (c *ConfigReader) checkValues(confPtr interface{}) error {
-	ref := reflect.ValueOf(confPtr).Elem()
 
-	return walkThroughStruct("", ref, c.checkValueOfField)
-}
-
-func (c *ConfigReader) checkValueOfField(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
-	checkFns := []FieldProcessor{
-		c.checkRequiredValueOfField,
-		c.validateValueOfField,
+	if key, ok := c.flagKeys[flagname]; ok {
+		return fmt.Errorf("flag [--%s] is bound to both [%s] and [%s]", flagname, key, fieldkey)
 	}
+	c.flagKeys[flagname] = fieldkey
 
-	for _, check := range checkFns {
-		if err := check(fieldKey, structField, structRef); err != nil {
+	fs := c.FlagSet()
+	flag := fs.Lookup(flagname)
+	if flag == nil && c.ownFlagSet {
+		err := registerFlag(fs, fieldkey, structField)
+		if err != nil {
 			return err
 		}
+		flag = fs.Lookup(flagname)
 	}
 
-	return nil
-}
-
-func (c *ConfigReader) checkRequiredValueOfField(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
-	required := structField.Tag.Get(tagRequired) == "true"
-	if required {
-		if c.viper.Get(fieldKey) == nil {
-			return fmt.Errorf("[%s] is required", fieldKey)
-		}
+	// ignore flag if cannot find it
+	if flag != nil {
+		return c.viper.BindPFlag(fieldkey, flag)
 	}
-
 	return nil
 }
 
-////////// Value Validation
+func (c *ConfigReader) lookupFlag(flagname string) *pflag.Flag {
+	return c.FlagSet().Lookup(flagname)
+}
 
-func (c *ConfigReader) validateValueOfField(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
-	// If there is no value, ignore the validation check
-	// If the value is required, it should use the require check
-	val := c.viper.Get(fieldKey)
-	if val == nil {
-		return nil
-	}
+////////// Check Values
 
-	validation := structField.Tag.Get(tagValidation)
-	if len(validation) > 0 {
-		splits := strings.Split(validation, ":")
-		if len(splits) < 2 {
-			return fmt.Errorf("invalid validation [%s] of key [%s]", validation, fieldKey)
-		}
+// Validator could be implemented by the config struct and its sub-structs,
+// Validate is called after the values are populated into the struct
+type Validator interface {
+	Validate() error
+}
 
-		action := splits[0]
-		rules := splits[1]
+func (c *ConfigReader) validateStructs(confPtr interface{}) ValidationErrors {
+	var errs ValidationErrors
 
-		if action != "in" && action != "range" {
-			return fmt.Errorf("unsupported action of validation [%s] of key [%s]", validation, fieldKey)
-		}
-		inAction := true
-		if action == "range" {
-			inAction = false
+	ref := reflect.ValueOf(confPtr).Elem()
+	_ = walkThroughStructs("", ref, func(fullKey string, structRef reflect.Value) error {
+		validator, ok := structRef.Addr().Interface().(Validator)
+		if !ok || c.hasUnresolvedKeys(fullKey) {
+			return nil
 		}
 
-		// Prepare for validation
-		validationFailed := fmt.Errorf("[%s] did not pass validation. want [%s] real [%v]", fieldKey, validation, val)
-		validationBadVal := fmt.Errorf("[%s] failed to parse validation values [%s]", fieldKey, validation)
-
-		var ruleSplits []string
-		var lAct, rAct, lValStr, rValStr string
-		var err error
-
-		if inAction {
-			rules = strings.TrimSpace(rules)
-			rules = strings.TrimLeft(rules, "[")
-			rules = strings.TrimRight(rules, "]")
-			ruleSplits = strings.Split(rules, ",")
-		} else {
-			lAct, rAct, lValStr, rValStr, err = parseRangeRule(rules)
-			if err != nil {
-				return err
+		if err := validator.Validate(); err != nil {
+			structErr := xerrors.Errorf("config is invalid: %w", err)
+			if fullKey != "" {
+				structErr = xerrors.Errorf("[%s] is invalid: %w", fullKey, err)
 			}
+			errs = append(errs, &FieldError{Key: fullKey, Rule: "Validate()", Err: structErr})
 		}
+		return nil
+	})
 
-		// let's start check by value types
-		// Handle time.Duration as a special case
-		typeName := structField.Type.String()
-		switch typeName {
-		case "time.Duration":
-			val := c.viper.GetDuration(fieldKey).Nanoseconds()
-			if inAction {
-				for _, s := range ruleSplits {
-					s = strings.TrimSpace(s)
-					vd, err := time.ParseDuration(s)
-					if err != nil {
-						return validationBadVal
-					}
-					v := vd.Nanoseconds()
+	return errs
+}
 
-					if val == v {
-						return nil
-					}
-				}
-				return validationFailed
-			} else {
-				var lValDur, rValDur time.Duration
-				var lVal, rVal int64
-				if lAct != "inf" {
-					lValDur, err = time.ParseDuration(lValStr)
-					if err != nil {
-						return validationBadVal
-					}
-					lVal = lValDur.Nanoseconds()
-				}
-				if rAct != "inf" {
-					rValDur, err = time.ParseDuration(rValStr)
-					if err != nil {
-						return validationBadVal
-					}
-					rVal = rValDur.Nanoseconds()
-				}
+func (c *ConfigReader) checkValues(confPtr interface{}) error {
+	ref := reflect.ValueOf(confPtr).Elem()
 
-				if (lAct == ">=" && val < lVal) ||
-					(lAct == ">" && val <= lVal) ||
-					(rAct == "<=" && val > rVal) ||
-					(rAct == "<" && val >= rVal) {
-					return validationFailed
-				}
-				return nil
-			}
+	var errs ValidationErrors
+	err := walkThroughStruct("", ref, func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+		if fieldErr := c.checkValueOfField(fieldKey, structField, structRef); fieldErr != nil {
+			errs = append(errs, fieldErr)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		// Handle base types
-		switch structRef.Kind() {
-		case reflect.String:
-			val := c.viper.GetString(fieldKey)
-			if inAction {
-				for _, s := range ruleSplits {
-					s = strings.TrimSpace(s)
-					if val == s {
-						return nil
-					}
-
-					last := len(s) - 1
-					sign := byte('\'')
-					if (len(s) > 3) && (s[0] == 'r') && (s[1] == sign) && (s[last] == sign) {
-						// This is a regex
-						s = s[2:last]
-						r, err := regexp.Compile(s)
-						if err != nil {
-							return validationBadVal
-						}
-						if r.MatchString(val) {
-							return nil
-						}
-					}
-				}
-				return validationFailed
-			} else {
-				// range in string means the range of the length
-				var lVal, rVal int64
-				if lAct != "inf" {
-					lVal, err = strconv.ParseInt(lValStr, 10, 64)
-					if err != nil {
-						return validationBadVal
-					}
-				}
-				if rAct != "inf" {
-					rVal, err = strconv.ParseInt(rValStr, 10, 64)
-					if err != nil {
-						return validationBadVal
-					}
-				}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
 
-				valLen := int64(len(val))
-				if (lAct == ">=" && valLen < lVal) ||
-					(lAct == ">" && valLen <= lVal) ||
-					(rAct == "<=" && valLen > rVal) ||
-					(rAct == "<" && valLen >= rVal) {
-					return validationFailed
-				}
-				return nil
-			}
-		case reflect.Float32, reflect.Float64:
-			val := c.viper.GetFloat64(fieldKey)
-			if inAction {
-				for _, s := range ruleSplits {
-					s = strings.TrimSpace(s)
-					v, err := strconv.ParseFloat(s, 64)
-					if err != nil {
-						return validationBadVal
-					}
+func (c *ConfigReader) checkValueOfField(fieldKey string, structField reflect.StructField, structRef reflect.Value) *FieldError {
+	if err := c.checkRequiredValueOfField(fieldKey, structField, structRef); err != nil {
+		return c.newFieldError(fieldKey, structField, tagRequired, err)
+	}
 
-					if math.Abs(val-v) < 1e-3 {
-						return nil
-					}
-				}
-				return validationFailed
-			} else {
-				var lVal, rVal float64
-				if lAct != "inf" {
-					lVal, err = strconv.ParseFloat(lValStr, 64)
-					if err != nil {
-						return validationBadVal
-					}
-				}
-				if rAct != "inf" {
-					rVal, err = strconv.ParseFloat(rValStr, 64)
-					if err != nil {
-						return validationBadVal
-					}
-				}
+	if err := c.checkExcludedValueOfField(fieldKey, structField, structRef); err != nil {
+		return c.newFieldError(fieldKey, structField, tagExcluded, err)
+	}
 
-				if (lAct == ">=" && val < lVal) ||
-					(lAct == ">" && val <= lVal) ||
-					(rAct == "<=" && val > rVal) ||
-					(rAct == "<" && val >= rVal) {
-					return validationFailed
-				}
-				return nil
-			}
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			val := c.viper.GetInt64(fieldKey)
-			if inAction {
-				for _, s := range ruleSplits {
-					s = strings.TrimSpace(s)
-					v, err := strconv.ParseInt(s, 10, 64)
-					if err != nil {
-						return validationBadVal
-					}
+	if err := c.validateValueOfField(fieldKey, structField, structRef); err != nil {
+		return c.newFieldError(fieldKey, structField, tagValidation, err)
+	}
 
-					if val == v {
-						return nil
-					}
-				}
-				return validationFailed
-			} else {
-				var lVal, rVal int64
-				if lAct != "inf" {
-					lVal, err = strconv.ParseInt(lValStr, 10, 64)
-					if err != nil {
-						return validationBadVal
-					}
-				}
-				if rAct != "inf" {
-					rVal, err = strconv.ParseInt(rValStr, 10, 64)
-					if err != nil {
-						return validationBadVal
-					}
-				}
+	return nil
+}
 
-				if (lAct == ">=" && val < lVal) ||
-					(lAct == ">" && val <= lVal) ||
-					(rAct == "<=" && val > rVal) ||
-					(rAct == "<" && val >= rVal) {
-					return validationFailed
-				}
-				return nil
-			}
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			val := c.viper.GetUint64(fieldKey)
-			if inAction {
-				for _, s := range ruleSplits {
-					s = strings.TrimSpace(s)
-					v, err := strconv.ParseUint(s, 10, 64)
-					if err != nil {
-						return validationBadVal
-					}
+func (c *ConfigReader) newFieldError(fieldKey string, structField reflect.StructField, tagName string, err error) *FieldError {
+	fieldErr := &FieldError{
+		Key:   fieldKey,
+		Rule:  fmt.Sprintf("%s:%q", tagName, structField.Tag.Get(tagName)),
+		Value: c.viper.Get(fieldKey),
+		Err:   err,
+	}
+	if c.isSecretField(fieldKey, structField) {
+		fieldErr.Value = maskValue(fieldErr.Value)
+	}
+	if source := c.provenance(fieldKey, structField).Source; source != nil {
+		fieldErr.Source = source.String()
+	}
+	return fieldErr
+}
 
-					if val == v {
-						return nil
-					}
-				}
-				return validationFailed
-			} else {
-				var lVal, rVal uint64
-				if lAct != "inf" {
-					lVal, err = strconv.ParseUint(lValStr, 10, 64)
-					if err != nil {
-						return validationBadVal
-					}
-				}
-				if rAct != "inf" {
-					rVal, err = strconv.ParseUint(rValStr, 10, 64)
-					if err != nil {
-						return validationBadVal
-					}
-				}
+func (c *ConfigReader) checkRequiredValueOfField(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+	required := structField.Tag.Get(tagRequired)
+	if required == "" || c.viper.Get(fieldKey) != nil {
+		return nil
+	}
 
-				if (lAct == ">=" && val < lVal) ||
-					(lAct == ">" && val <= lVal) ||
-					(rAct == "<=" && val > rVal) ||
-					(rAct == "<" && val >= rVal) {
-					return validationFailed
-				}
-				return nil
-			}
-		default:
-			return validationBadVal
-		}
+	met, err := c.checkCondition(required)
+	if err != nil {
+		return fmt.Errorf("[%s] invalid required condition [%s]: %v", fieldKey, required, err)
+	}
+	if !met {
+		return nil
 	}
 
-	return nil
+	if required == "true" {
+		return fmt.Errorf("[%s] is required", fieldKey)
+	}
+	return fmt.Errorf("[%s] is required when [%s]", fieldKey, required)
 }
 
-func parseRangeRule(rules string) (lAct, rAct, lVal, rVal string, err error) {
-	invalidRule := fmt.Errorf("invalid range rule: [%s]", rules)
-
-	rules = strings.TrimSpace(rules)
+func (c *ConfigReader) checkExcludedValueOfField(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+	excluded := structField.Tag.Get(tagExcluded)
+	if excluded == "" || c.viper.Get(fieldKey) == nil {
+		return nil
+	}
 
-	switch rules[0] {
-	case '[':
-		lAct = ">="
-	case '(':
-		lAct = ">"
-	default:
-		err = invalidRule
-		return
+	met, err := c.checkCondition(excluded)
+	if err != nil {
+		return fmt.Errorf("[%s] invalid excluded condition [%s]: %v", fieldKey, excluded, err)
+	}
+	if !met {
+		return nil
 	}
-	rules = rules[1:]
 
-	last := len(rules) - 1
-	switch rules[last] {
-	case ']':
-		rAct = "<="
-	case ')':
-		rAct = "<"
-	default:
-		err = invalidRule
-		return
+	if excluded == "true" {
+		return fmt.Errorf("[%s] is not allowed", fieldKey)
 	}
-	rules = rules[:last]
+	return fmt.Errorf("[%s] is not allowed when [%s]", fieldKey, excluded)
+}
 
-	splits := strings.Split(rules, ",")
-	if len(splits) != 2 {
-		err = invalidRule
-		return
+// checkCondition tells if the condition of required and excluded tags is met
+//
+//	true              always
+//	if=key            the value of key is true
+//	if=key=value      the value of key equals to value
+//	unless=key[=val]  the opposite of if
+//	with=key1,key2    any of the keys is set
+//	without=key1,key2 any of the keys is not set
+func (c *ConfigReader) checkCondition(cond string) (bool, error) {
+	idx := strings.Index(cond, "=")
+	if idx < 0 {
+		return cond == "true", nil
 	}
 
-	lVal = strings.TrimSpace(splits[0])
-	rVal = strings.TrimSpace(splits[1])
+	op := strings.TrimSpace(cond[:idx])
+	args := strings.TrimSpace(cond[idx+1:])
+	switch op {
+	case condIf, condUnless:
+		key := args
+		value := ""
+		hasValue := false
+		if idx := strings.Index(args, "="); idx >= 0 {
+			key = strings.TrimSpace(args[:idx])
+			value = strings.TrimSpace(args[idx+1:])
+			hasValue = true
+		}
+		if err := c.checkConditionKey(key); err != nil {
+			return false, err
+		}
 
-	if len(lVal) == 0 {
-		lAct = "inf"
+		met := false
+		if hasValue {
+			met = c.viper.Get(key) != nil && c.viper.GetString(key) == value
+		} else {
+			met = c.viper.GetBool(key)
+		}
+		return met == (op == condIf), nil
+	case condWith, condWithout:
+		for _, key := range strings.Split(args, ",") {
+			key = strings.TrimSpace(key)
+			if err := c.checkConditionKey(key); err != nil {
+				return false, err
+			}
+
+			isSet := c.viper.Get(key) != nil
+			if isSet == (op == condWith) {
+				return true, nil
+			}
+		}
+		return false, nil
 	}
-	if len(rVal) == 0 {
-		rAct = "inf"
+
+	return false, fmt.Errorf("unsupported condition [%s]", op)
+}
+
+// checkConditionKey makes sure the key in the condition is a field of the config struct
+func (c *ConfigReader) checkConditionKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("missing key")
 	}
-	if lAct == "inf" && rAct == "inf" {
-		err = invalidRule
-		return
+	if _, ok := c.fields[strings.ToLower(key)]; !ok {
+		return fmt.Errorf("unknown key [%s]", key)
 	}
-
-	return
+	return nil
 }
 
 ////////
@@ -782,6 +878,29 @@ func (c *ConfigReader) populateStructValues(confPtr interface{}) error {
 	})
 }
 
+// decodeValue converts the raw viper value into the type of the field,
+// the same way as viper does when unmarshal values into the struct
+func decodeValue(input interface{}, typ reflect.Type) (interface{}, error) {
+	ref := reflect.New(typ)
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		TagName:          tagKey,
+		Result:           ref.Interface(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		return nil, err
+	}
+	return ref.Elem().Interface(), nil
+}
+
 func populateStructField(field reflect.StructField, fieldValue reflect.Value, value string) error {
 	typeName := field.Type.String()
 	switch typeName {
@@ -870,43 +989,12 @@ func walkThroughStruct(rootKey string, structRef reflect.Value, processField Fie
 	for i := 0; i < structType.NumField(); i++ {
 		currentField := structRef.Field(i)
 		structField := structType.Field(i)
-		tag := structField.Tag
 
-		squash := structField.Type.Kind() == reflect.Struct && structField.Anonymous
-
-		fieldKey := tag.Get(tagKey)
-		// Deal with the "," in the key defines, not pass it during the walk through
-		if strings.Contains(fieldKey, ",") {
-			keys := strings.Split(fieldKey, ",")
-			fieldKey = keys[0]
-
-			if keys[1] == "squash" {
-				squash = true
-			}
-		}
-		if len(fieldKey) <= 0 && !squash {
-			fieldKey = strings.ToLower(structField.Name)
-		}
-
-		if squash {
-			fieldKey = ""
-		}
-
-		if fieldKey == skipKey {
+		fullFieldKey, ok := structFieldKey(rootKey, structField)
+		if !ok {
 			continue
 		}
 
-		fullFieldKey := ""
-		if rootKey != "" {
-			if fieldKey != "" {
-				fullFieldKey = rootKey + "." + fieldKey
-			} else {
-				fullFieldKey = rootKey
-			}
-		} else {
-			fullFieldKey = fieldKey
-		}
-
 		if ast.IsExported(structField.Name) {
 			switch structField.Type.Kind() {
 			case reflect.Struct:
@@ -926,6 +1014,88 @@ func walkThroughStruct(rootKey string, structRef reflect.Value, processField Fie
 	return nil
 }
 
+// structFieldKey returns the full key of the field under the rootKey, false if the field is skipped
+func structFieldKey(rootKey string, structField reflect.StructField) (string, bool) {
+	tag := structField.Tag
+
+	squash := structField.Type.Kind() == reflect.Struct && structField.Anonymous
+
+	fieldKey := tag.Get(tagKey)
+	// Deal with the "," in the key defines, not pass it during the walk through
+	if strings.Contains(fieldKey, ",") {
+		keys := strings.Split(fieldKey, ",")
+		fieldKey = keys[0]
+
+		if keys[1] == "squash" {
+			squash = true
+		}
+	}
+	if len(fieldKey) <= 0 && !squash {
+		fieldKey = strings.ToLower(structField.Name)
+	}
+
+	if squash {
+		fieldKey = ""
+	}
+
+	if fieldKey == skipKey {
+		return "", false
+	}
+
+	fullFieldKey := ""
+	if rootKey != "" {
+		if fieldKey != "" {
+			fullFieldKey = rootKey + "." + fieldKey
+		} else {
+			fullFieldKey = rootKey
+		}
+	} else {
+		fullFieldKey = fieldKey
+	}
+
+	return fullFieldKey, true
+}
+
+// StructProcessor process the root struct or one of the sub-structs of the config
+type StructProcessor func(fullKey string, structRef reflect.Value) error
+
+// walkThroughStructs calls processStruct with the root struct and all the sub-structs.
+// Embedded structs are not passed, as their methods are promoted to the outer struct
+func walkThroughStructs(rootKey string, structRef reflect.Value, processStruct StructProcessor) error {
+	if err := processStruct(rootKey, structRef); err != nil {
+		return err
+	}
+
+	return walkThroughSubStructs(rootKey, structRef, processStruct)
+}
+
+func walkThroughSubStructs(rootKey string, structRef reflect.Value, processStruct StructProcessor) error {
+	structType := structRef.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		if structField.Type.Kind() != reflect.Struct || !ast.IsExported(structField.Name) {
+			continue
+		}
+
+		fullFieldKey, ok := structFieldKey(rootKey, structField)
+		if !ok {
+			continue
+		}
+
+		var err error
+		if structField.Anonymous {
+			err = walkThroughSubStructs(fullFieldKey, structRef.Field(i), processStruct)
+		} else {
+			err = walkThroughStructs(fullFieldKey, structRef.Field(i), processStruct)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 ////////
 
 func isZeroOfUnderlyingType(x interface{}) bool {
@@ -937,12 +1107,14 @@ func isZeroOfUnderlyingType(x interface{}) bool {
 // For dump config to file
 
 // DumpConfig wraps the global ConfigReader instance
-func DumpConfig(filename string, confPtr interface{}) error {
-	return c.DumpConfig(filename, confPtr)
+func DumpConfig(filename string, confPtr interface{}, opts ...Option) error {
+	return c.DumpConfig(filename, confPtr, opts...)
 }
 
-// DumpConfig dumps the merged config to filepath
-func (c *ConfigReader) DumpConfig(filename string, confPtr interface{}) error {
+// DumpConfig dumps the merged config to filepath, it fails if the file exists unless WithOverwrite,
+// use WithAtomicWrite to write a temp file and rename it into place, and WithFileMode to set the mode.
+// The secrets are masked unless WithUnmaskedSecrets.
+func (c *ConfigReader) DumpConfig(filename string, confPtr interface{}, opts ...Option) error {
 	var configType string
 
 	ext := filepath.Ext(filename)
@@ -956,55 +1128,383 @@ func (c *ConfigReader) DumpConfig(filename string, confPtr interface{}) error {
 		return fmt.Errorf("config type [%s] is not supported", configType)
 	}
 
-	config := confPtr
-	if config == nil {
-		config = make(map[string]interface{})
+	o := newOptions(opts)
+	settings, err := configSettings(confPtr, !o.unmasked)
+	if err != nil {
+		return err
+	}
+	if !o.unmasked {
+		c.maskResolvedValues(settings)
+	}
+
+	// marshal before opening the file, so it's not left broken if the config could not be marshaled
+	buf := new(bytes.Buffer)
+	if err := marshalWriter(buf, configType, settings, nil); err != nil {
+		return err
+	}
+
+	if o.atomic {
+		return c.dumpConfigAtomic(filename, buf.Bytes(), o)
 	}
 
-	force := false
 	flags := os.O_CREATE | os.O_TRUNC | os.O_WRONLY
-	if !force {
+	if !o.overwrite {
 		flags |= os.O_EXCL
 	}
-	filePermission := os.FileMode(0644)
-	f, err := c.fs.OpenFile(filename, flags, filePermission)
+	f, err := c.fs.OpenFile(filename, flags, o.fileMode)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	if err := marshalWriter(f, configType, config); err != nil {
+	// the mode is only set by OpenFile when it creates the file
+	if o.fileModeSet {
+		if err := c.fs.Chmod(filename, o.fileMode); err != nil {
+			return err
+		}
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
 		return err
 	}
 
 	return f.Sync()
 }
 
-var SupportedExts = []string{"json", "yaml", "yml"}
+// dumpConfigAtomic writes the config into a temp file in the same directory and renames it into place,
+// so the file is never half written
+func (c *ConfigReader) dumpConfigAtomic(filename string, data []byte, o *options) (err error) {
+	if !o.overwrite {
+		exists, err := afero.Exists(c.fs, filename)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+		}
+	}
+
+	f, err := afero.TempFile(c.fs, filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = c.fs.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	// the temp file is created as 0600, the existing file keeps its mode
+	mode := o.fileMode
+	if info, statErr := c.fs.Stat(filename); statErr == nil && !o.fileModeSet {
+		mode = info.Mode().Perm()
+	}
+	if err = c.fs.Chmod(f.Name(), mode); err != nil {
+		return err
+	}
+
+	if o.overwrite {
+		return c.fs.Rename(f.Name(), filename)
+	}
+	return c.renameNoReplace(f.Name(), filename)
+}
+
+// renameNoReplace moves the file into place unless the target exists. On the OS filesystem it's atomic
+// by a hard link, where links are supported, otherwise the target is checked right before renaming,
+// so a file created in between could still be replaced.
+func (c *ConfigReader) renameNoReplace(oldname string, newname string) error {
+	if _, ok := c.fs.(*afero.OsFs); ok {
+		err := os.Link(oldname, newname)
+		if err == nil {
+			// the target is in place already, the temp file is only another link to it
+			_ = os.Remove(oldname)
+			return nil
+		}
+		if os.IsExist(err) {
+			return err
+		}
+	}
+
+	exists, err := afero.Exists(c.fs, newname)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return &os.PathError{Op: "rename", Path: newname, Err: os.ErrExist}
+	}
+	return c.fs.Rename(oldname, newname)
+}
+
+// DumpConfigTo wraps the global ConfigReader instance
+func DumpConfigTo(w io.Writer, configType string, confPtr interface{}, opts ...Option) error {
+	return c.DumpConfigTo(w, configType, confPtr, opts...)
+}
+
+// DumpConfigTo writes the config to w in the configType, any of SupportedExts,
+// the secrets are masked unless WithUnmaskedSecrets
+func (c *ConfigReader) DumpConfigTo(w io.Writer, configType string, confPtr interface{}, opts ...Option) error {
+	if !stringInSlice(configType, SupportedExts) {
+		return fmt.Errorf("config type [%s] is not supported", configType)
+	}
+
+	mask := !newOptions(opts).unmasked
+	settings, err := configSettings(confPtr, mask)
+	if err != nil {
+		return err
+	}
+	if mask {
+		c.maskResolvedValues(settings)
+	}
+	return marshalWriter(w, configType, settings, nil)
+}
+
+// SupportedExts are the config types DumpConfig can write, the same as viper can read
+var SupportedExts = []string{"json", "yaml", "yml", "toml", "hcl", "ini", "properties", "props", "prop", "dotenv", "env"}
+
+// marshalWriter writes the settings built by configSettings, the comments by the keys are written above them,
+// which is not supported by json and hcl.
+func marshalWriter(w io.Writer, configType string, settings map[string]interface{}, comments map[string]string) error {
+	if comments != nil {
+		switch configType {
+		case "json", "hcl":
+			return fmt.Errorf("comments are not supported in config type [%s]", configType)
+		case "yaml", "yml", "toml":
+			return commentedWriter(w, configType, settings, comments)
+		}
+	}
+	written := make(map[string]bool)
 
-func marshalWriter(f afero.File, configType string, config interface{}) error {
 	switch configType {
 	case "json":
-		b, err := json.MarshalIndent(config, "", "  ")
+		b, err := json.MarshalIndent(settings, "", "  ")
 		if err != nil {
 			return err
 		}
-		_, err = f.WriteString(string(b))
-		if err != nil {
+		if _, err = w.Write(b); err != nil {
 			return err
 		}
 	case "yaml", "yml":
-		b, err := yaml.Marshal(config)
+		b, err := yaml.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(b); err != nil {
+			return err
+		}
+	case "toml":
+		t, err := toml.TreeFromMap(settings)
+		if err != nil {
+			return err
+		}
+		if _, err = t.WriteTo(w); err != nil {
+			return err
+		}
+	case "hcl":
+		// the nested keys are dotted, since viper reads the blocks of hcl as lists
+		flat, err := flatConfigSettings(settings)
 		if err != nil {
 			return err
 		}
-		if _, err = f.WriteString(string(b)); err != nil {
+		b, err := json.Marshal(flat)
+		if err != nil {
+			return err
+		}
+		node, err := hcl.Parse(string(b))
+		if err != nil {
+			return err
+		}
+		if err = printer.Fprint(w, node.Node); err != nil {
+			return err
+		}
+	case "ini":
+		values, err := stringConfigSettings(settings)
+		if err != nil {
+			return err
+		}
+		cfg := ini.Empty()
+		for _, key := range sortedKeys(values) {
+			// viper reads the keys out of the sections as default.<key>
+			if !strings.Contains(key, ".") {
+				return fmt.Errorf("[%s] the top-level keys could not be read back from ini, put them in a table", key)
+			}
+		}
+		for _, key := range sortedKeys(values) {
+			section, name := "", key
+			if i := strings.LastIndex(key, "."); i >= 0 {
+				section, name = key[:i], key[i+1:]
+			}
+			k := cfg.Section(section).Key(name)
+			k.SetValue(values[key])
+			if comment := keyComment(comments, key, written); comment != "" {
+				k.Comment = "# " + comment
+			}
+		}
+		if _, err = cfg.WriteTo(w); err != nil {
+			return err
+		}
+	case "properties", "props", "prop":
+		values, err := stringConfigSettings(settings)
+		if err != nil {
 			return err
 		}
+		p := properties.NewProperties()
+		for _, key := range sortedKeys(values) {
+			if _, _, err = p.Set(key, values[key]); err != nil {
+				return err
+			}
+			if comment := keyComment(comments, key, written); comment != "" {
+				p.SetComment(key, comment)
+			}
+		}
+		if _, err = p.WriteComment(w, "# ", properties.UTF8); err != nil {
+			return err
+		}
+	case "dotenv", "env":
+		values, err := stringConfigSettings(settings)
+		if err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(values) {
+			if comment := keyComment(comments, key, written); comment != "" {
+				if _, err = fmt.Fprintf(w, "# %s\n", comment); err != nil {
+					return err
+				}
+			}
+			// the dots of the nested keys are kept, so the file can be read back
+			if _, err = fmt.Fprintf(w, "%s=%s\n", key, strconv.Quote(values[key])); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
+// configSettings converts the config into the nested maps of the settings, as the tree of the keys
+// walked through the struct, which mirrors the keys the reader expects. mask tells to mask the secrets.
+func configSettings(config interface{}, mask bool) (map[string]interface{}, error) {
+	if config == nil {
+		return make(map[string]interface{}), nil
+	}
+
+	ref := reflect.ValueOf(config)
+	if ref.IsValid() {
+		if settings, ok := plainValue(ref, mask).(map[string]interface{}); ok {
+			return settings, nil
+		}
+	}
+	return nil, fmt.Errorf("config must be a struct or a map, got %T", config)
+}
+
+// flatConfigSettings converts the settings into the ones of the dotted keys,
+// the tables are only allowed in the lists if they are flat
+func flatConfigSettings(settings map[string]interface{}) (map[string]interface{}, error) {
+	flat := make(map[string]interface{})
+	var flatten func(prefix string, settings map[string]interface{}) error
+	flatten = func(prefix string, settings map[string]interface{}) error {
+		for key, value := range settings {
+			key = prefix + key
+			switch v := value.(type) {
+			case map[string]interface{}:
+				if err := flatten(key+".", v); err != nil {
+					return err
+				}
+				continue
+			case []interface{}:
+				for _, item := range v {
+					table, ok := item.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					for _, field := range table {
+						switch field.(type) {
+						case map[string]interface{}, []interface{}:
+							return fmt.Errorf("[%s] the nested tables in the list could not be flattened", key)
+						}
+					}
+				}
+			}
+			flat[key] = value
+		}
+		return nil
+	}
+	if err := flatten("", settings); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+// stringConfigSettings converts the settings into the string values of the dotted keys,
+// the lists are joined by commas, which could not hold the tables
+func stringConfigSettings(settings map[string]interface{}) (map[string]string, error) {
+	flat, err := flatConfigSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(flat))
+	for key, value := range flat {
+		list, ok := value.([]interface{})
+		if !ok {
+			values[key] = fmt.Sprint(value)
+			continue
+		}
+
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("[%s] the list of tables or lists could not be flattened", key)
+			}
+			// the items are split by commas when they are read back
+			if strings.Contains(fmt.Sprint(item), ",") {
+				return nil, fmt.Errorf("[%s] the list items containing commas could not be joined", key)
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		values[key] = strings.Join(items, ",")
+	}
+	return values, nil
+}
+
+// keyComment returns the comment of the dotted key, or of the field it belongs to, e.g. a map,
+// only if it's not written yet
+func keyComment(comments map[string]string, key string, written map[string]bool) string {
+	for {
+		if comment, ok := comments[key]; ok {
+			if written[key] {
+				return ""
+			}
+			written[key] = true
+			return comment
+		}
+
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return ""
+		}
+		key = key[:i]
+	}
+}
+
+func sortedKeys(settings map[string]string) []string {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func stringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if a == b {
@@ -1013,3 +1513,49 @@ func stringInSlice(a string, list []string) bool {
 	}
 	return false
 }
+
+// deepCopy copies the value with the maps, slices and pointers in it, so the copy shares nothing with the value.
+// The unexported fields of structs are copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	dup := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			dup.Set(reflect.New(v.Type().Elem()))
+			dup.Elem().Set(deepCopy(v.Elem()))
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			dup.Set(deepCopy(v.Elem()))
+		}
+	case reflect.Map:
+		if !v.IsNil() {
+			dup.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+			iter := v.MapRange()
+			for iter.Next() {
+				dup.SetMapIndex(deepCopy(iter.Key()), deepCopy(iter.Value()))
+			}
+		}
+	case reflect.Slice:
+		if !v.IsNil() {
+			dup.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				dup.Index(i).Set(deepCopy(v.Index(i)))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			dup.Index(i).Set(deepCopy(v.Index(i)))
+		}
+	case reflect.Struct:
+		dup.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if dup.Field(i).CanSet() {
+				dup.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+	default:
+		dup.Set(v)
+	}
+	return dup
+}
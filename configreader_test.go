@@ -2,7 +2,12 @@ package configreader
 
 import (
 	"bytes"
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -363,6 +368,61 @@ func TestRequired(t *testing.T) {
 	assert.Contains(t, err.Error(), "required")
 }
 
+func TestRequiredConditions(t *testing.T) {
+	defer testTearDown()
+
+	type TLSConfig struct {
+		Enabled bool
+		Cert    string `required:"if=tls.enabled"`
+		Key     string `required:"with=tls.cert"`
+		CA      string `excluded:"unless=tls.enabled"`
+	}
+
+	type testConfig struct {
+		Mode   string `default:"local"`
+		Token  string `required:"unless=mode=local"`
+		Host   string `required:"without=socket"`
+		Socket string
+		TLS    TLSConfig `key:"tls"`
+	}
+
+	configData := []byte(`{
+		"mode": "prod",
+		"socket": "/tmp/app.sock",
+		"tls": {
+			"cert": "/etc/tls.crt",
+			"ca": "/etc/ca.crt"
+		}
+	}`)
+
+	fs := afero.NewMemMapFs()
+	err := writeFile(fs, "/tmp/config.json", configData)
+	assert.Nil(t, err)
+
+	SetFs(fs)
+	AddConfigPath("/tmp")
+
+	conf := testConfig{}
+	err = LoadConfig(&conf)
+	assert.NotNil(t, err)
+
+	var verrs ValidationErrors
+	assert.ErrorAs(t, err, &verrs)
+	assert.Len(t, verrs, 3)
+	assert.Equal(t, "[token] is required when [unless=mode=local]", verrs[0].Error())
+	assert.Equal(t, "[tls.key] is required when [with=tls.cert]", verrs[1].Error())
+	assert.Equal(t, "[tls.ca] is not allowed when [unless=tls.enabled]", verrs[2].Error())
+	assert.Equal(t, `excluded:"unless=tls.enabled"`, verrs[2].Rule)
+
+	type badConfig struct {
+		Cert string `required:"if=tls.enabled"`
+	}
+
+	err = LoadConfig(&badConfig{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown key [tls.enabled]")
+}
+
 func TestSliceDefault(t *testing.T) {
 	type MyStruct struct {
 		IntSlice []int `default:"[8,10]"`
@@ -454,11 +514,11 @@ maps2s:
 	assert.Equal(t, contentYaml, string(bufYml))
 
 	contentJson := `{
-  "MapS2I": {
+  "maps2i": {
     "K1": 1,
     "k2": 2
   },
-  "MapS2S": {
+  "maps2s": {
     "K1": "V1",
     "k2": "2"
   }
@@ -471,10 +531,294 @@ maps2s:
 	assert.Nil(t, err)
 	assert.Equal(t, contentJson, string(bufJson))
 
-	tomlFile := "/tmp/test_config.toml"
+	tomlFile := "/tmp/test_config.xml"
 	err = DumpConfig(tomlFile, &conf)
 	assert.NotNil(t, err)
-	assert.Contains(t, err.Error(), "[toml] is not supported")
+	assert.Contains(t, err.Error(), "[xml] is not supported")
+}
+
+func TestDumpConfigKeys(t *testing.T) {
+	defer testTearDown()
+
+	fs := afero.NewMemMapFs()
+	SetFs(fs)
+
+	type BaseStruct struct {
+		Name string `key:"name"`
+	}
+
+	type DBConfig struct {
+		User     string `key:"user"`
+		Password string `key:"-"`
+	}
+
+	type MyStruct struct {
+		BaseStruct `key:",squash"`
+		Timeout    time.Duration `key:"timeout"`
+		DB         DBConfig      `key:"database"`
+	}
+
+	conf := MyStruct{
+		BaseStruct: BaseStruct{Name: "app"},
+		Timeout:    3 * time.Second,
+		DB:         DBConfig{User: "root", Password: "secret"},
+	}
+
+	filename := "/tmp/test_config.yaml"
+	err := DumpConfig(filename, &conf)
+	assert.Nil(t, err)
+
+	buf, err := afero.ReadFile(fs, filename)
+	assert.Nil(t, err)
+	assert.Equal(t, `database:
+  user: root
+name: app
+timeout: 3s
+`, string(buf))
+
+	actual := MyStruct{}
+	err = LoadFromFile(filename, &actual)
+	assert.Nil(t, err)
+	conf.DB.Password = ""
+	assert.Equal(t, conf, actual)
+}
+
+func TestDumpConfigOptions(t *testing.T) {
+	defer testTearDown()
+
+	fs := afero.NewMemMapFs()
+	SetFs(fs)
+
+	type MyStruct struct {
+		Name  string `key:"name"`
+		Ports []int  `key:"ports"`
+	}
+
+	filename := "/etc/app/config.yaml"
+	err := DumpConfig(filename, &MyStruct{Name: "a"})
+	assert.Nil(t, err)
+
+	err = DumpConfig(filename, &MyStruct{Name: "b"})
+	assert.True(t, os.IsExist(err))
+
+	err = DumpConfig(filename, &MyStruct{Name: "b"}, WithOverwrite(), WithFileMode(0600))
+	assert.Nil(t, err)
+	data, err := afero.ReadFile(fs, filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "name: b\nports: []\n", string(data))
+	info, err := fs.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// atomic writes leave nothing but the target
+	files := func() []string {
+		infos, err := afero.ReadDir(fs, "/etc/app")
+		assert.Nil(t, err)
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		return names
+	}
+
+	err = DumpConfig(filename, &MyStruct{Name: "c"}, WithAtomicWrite())
+	assert.True(t, os.IsExist(err))
+
+	err = DumpConfig(filename, &MyStruct{Name: "c"}, WithAtomicWrite(), WithOverwrite())
+	assert.Nil(t, err)
+	data, err = afero.ReadFile(fs, filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "name: c\nports: []\n", string(data))
+	// the existing file keeps its mode unless WithFileMode
+	info, err = fs.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.Equal(t, []string{"config.yaml"}, files())
+
+	err = DumpConfig(filename, &MyStruct{Name: "c"}, WithOverwrite())
+	assert.Nil(t, err)
+	info, err = fs.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	err = DumpConfig(filename, &MyStruct{Name: "c"}, WithAtomicWrite(), WithOverwrite(), WithFileMode(0640))
+	assert.Nil(t, err)
+	info, err = fs.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// the file created after the check is not replaced
+	err = writeFile(fs, "/etc/app/new.yaml", []byte("name: x\n"))
+	assert.Nil(t, err)
+	err = c.renameNoReplace(filename, "/etc/app/new.yaml")
+	assert.True(t, os.IsExist(err))
+	err = fs.Remove("/etc/app/new.yaml")
+	assert.Nil(t, err)
+
+	// the target is untouched if the dump fails
+	type BadStruct struct {
+		Ports [][]int `key:"ports"`
+	}
+	propsFile := "/etc/app/config.properties"
+	err = DumpConfig(propsFile, &MyStruct{Name: "d"}, WithAtomicWrite())
+	assert.Nil(t, err)
+	err = DumpConfig(propsFile, &BadStruct{Ports: [][]int{{1}}}, WithAtomicWrite(), WithOverwrite())
+	assert.EqualError(t, err, "[ports] the list of tables or lists could not be flattened")
+	data, err = afero.ReadFile(fs, propsFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "name = d\n")
+	assert.Equal(t, []string{"config.properties", "config.yaml"}, files())
+
+	buf := new(bytes.Buffer)
+	err = DumpConfigTo(buf, "json", &MyStruct{Name: "e", Ports: []int{80}})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"name": "e", "ports": [80]}`, buf.String())
+
+	err = DumpConfigTo(buf, "xml", &MyStruct{})
+	assert.EqualError(t, err, "config type [xml] is not supported")
+
+	// the OS filesystem links the temp file into place, which fails if the target exists
+	dir, err := ioutil.TempDir("", "configreader")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	r := New()
+	tmpFile, target := filepath.Join(dir, "tmp"), filepath.Join(dir, "config.yaml")
+	assert.Nil(t, ioutil.WriteFile(tmpFile, []byte("name: a\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(target, []byte("name: b\n"), 0644))
+	err = r.renameNoReplace(tmpFile, target)
+	assert.True(t, os.IsExist(err))
+	data, err = ioutil.ReadFile(target)
+	assert.Nil(t, err)
+	assert.Equal(t, "name: b\n", string(data))
+
+	assert.Nil(t, os.Remove(target))
+	err = r.renameNoReplace(tmpFile, target)
+	assert.Nil(t, err)
+	data, err = ioutil.ReadFile(target)
+	assert.Nil(t, err)
+	assert.Equal(t, "name: a\n", string(data))
+	_, err = os.Stat(tmpFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDumpConfigRoundTrip(t *testing.T) {
+	defer testTearDown()
+
+	type TLSConfig struct {
+		Enabled bool
+		Cert    string
+	}
+
+	type ServerConfig struct {
+		Host    string
+		Port    int
+		Timeout time.Duration
+		TLS     TLSConfig
+	}
+
+	type UpstreamConfig struct {
+		Addr   string
+		Weight int
+	}
+
+	type MyStruct struct {
+		Name      string
+		Ratio     float64
+		Debug     bool
+		Tags      []string
+		Ports     []int
+		Labels    map[string]string
+		Server    ServerConfig
+		Upstreams []UpstreamConfig
+	}
+
+	// viper reads the keys out of the sections of ini as default.<key>
+	type IniStruct struct {
+		App MyStruct
+	}
+
+	conf := MyStruct{
+		Name:   "my \"app\" # 1",
+		Ratio:  0.5,
+		Debug:  true,
+		Tags:   []string{"a", "b"},
+		Ports:  []int{80, 443},
+		Labels: map[string]string{"zone": "us-east", "tier": "web"},
+		Server: ServerConfig{
+			Host:    "localhost",
+			Port:    8080,
+			Timeout: 3 * time.Second,
+			TLS:     TLSConfig{Enabled: true, Cert: "/etc/cert.pem"},
+		},
+		Upstreams: []UpstreamConfig{{Addr: "a.local", Weight: 1}, {Addr: "b.local", Weight: 2}},
+	}
+
+	for _, ext := range SupportedExts {
+		expected := conf
+		if stringInSlice(ext, []string{"ini", "properties", "props", "prop", "dotenv", "env"}) {
+			// the flat formats can't hold the list of tables
+			expected.Upstreams = []UpstreamConfig{}
+		}
+
+		Reset()
+		fs := afero.NewMemMapFs()
+		SetFs(fs)
+		filename := "/etc/config." + ext
+
+		if ext == "ini" {
+			err := DumpConfig(filename, &expected)
+			assert.EqualError(t, err, "[debug] the top-level keys could not be read back from ini, put them in a table")
+			exists, _ := afero.Exists(fs, filename)
+			assert.False(t, exists)
+
+			err = DumpConfig(filename, &IniStruct{App: expected})
+			assert.Nil(t, err, ext)
+
+			actual := IniStruct{}
+			err = ReadFromFile(filename, &actual)
+			assert.Nil(t, err, ext)
+			assert.Equal(t, IniStruct{App: expected}, actual, ext)
+			continue
+		}
+
+		err := DumpConfig(filename, &expected)
+		assert.Nil(t, err, ext)
+
+		actual := MyStruct{}
+		err = ReadFromFile(filename, &actual)
+		assert.Nil(t, err, ext)
+		assert.Equal(t, expected, actual, ext)
+	}
+
+	err := DumpConfig("/etc/config.properties", &conf)
+	assert.EqualError(t, err, "[upstreams] the list of tables or lists could not be flattened")
+
+	// the flat formats split the lists by commas when reading them back
+	commas := IniStruct{App: MyStruct{Tags: []string{"a,b", "c"}}}
+	for _, ext := range SupportedExts {
+		Reset()
+		fs := afero.NewMemMapFs()
+		SetFs(fs)
+		filename := "/etc/config." + ext
+
+		err := DumpConfig(filename, &commas)
+		if stringInSlice(ext, []string{"ini", "properties", "props", "prop", "dotenv", "env"}) {
+			assert.EqualError(t, err, "[app.tags] the list items containing commas could not be joined", ext)
+			continue
+		}
+		assert.Nil(t, err, ext)
+
+		actual := IniStruct{}
+		err = ReadFromFile(filename, &actual)
+		assert.Nil(t, err, ext)
+		assert.Equal(t, []string{"a,b", "c"}, actual.App.Tags, ext)
+	}
+
+	type NestedStruct struct {
+		Servers []ServerConfig
+	}
+	err = DumpConfig("/etc/config.hcl", &NestedStruct{Servers: []ServerConfig{{}}})
+	assert.EqualError(t, err, "[servers] the nested tables in the list could not be flattened")
 }
 
 func TestEnvValue(t *testing.T) {
@@ -772,3 +1116,49 @@ func TestValidationDuration(t *testing.T) {
 	err = LoadConfig(&conf)
 	assert.Nil(t, err)
 }
+
+func TestValidationCustom(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	configData := []byte(`{
+		"addr": "localhost:8080",
+		"bad": "localhost",
+		"port": "80"
+	}`)
+	filename := "/tmp/config.json"
+	err := writeFile(fs, filename, configData)
+	assert.Nil(t, err)
+
+	type Conf struct {
+		Addr string `validation:"hostport"`
+		Bad  string `validation:"hostport"`
+		Port int    `validation:"even"`
+	}
+
+	errNoPort := errors.New("missing port")
+	RegisterValidator("hostport", func(fieldKey string, fieldType reflect.Type, value interface{}) error {
+		if !strings.Contains(value.(string), ":") {
+			return errNoPort
+		}
+		return nil
+	})
+	RegisterValidator("even", func(fieldKey string, fieldType reflect.Type, value interface{}) error {
+		assert.Equal(t, "port", fieldKey)
+		assert.Equal(t, reflect.Int, fieldType.Kind())
+		assert.Equal(t, 80, value)
+		return nil
+	})
+
+	conf := Conf{}
+
+	SetConfigName("config")
+	AddConfigPath("/tmp")
+
+	err = LoadConfig(&conf)
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, errNoPort))
+	assert.Contains(t, err.Error(), "[bad] did not pass validation [hostport]")
+}
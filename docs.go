@@ -0,0 +1,169 @@
+package configreader
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"reflect"
+	"strings"
+)
+
+// The formats of GenerateDocs
+const (
+	DocsMarkdown = "markdown"
+	DocsHTML     = "html"
+)
+
+// docsColumns are the columns of the reference table
+var docsColumns = []string{"Key", "Type", "Default", "Env", "Flag", "Required", "Validation", "Description"}
+
+// docsRow is one key of the reference, the values are empty if not set
+type docsRow struct {
+	key         string
+	typ         string
+	defval      string
+	envs        []string
+	flag        string
+	required    string
+	validation  string
+	description string
+}
+
+// GenerateDocs wraps the global ConfigReader instance
+func GenerateDocs(confPtr interface{}, format string) ([]byte, error) {
+	return c.GenerateDocs(confPtr, format)
+}
+
+// GenerateDocs generates the reference of the config struct in a table, format is DocsMarkdown or DocsHTML.
+// Every key is listed with its type, default, env vars with the prefix of the reader, flag, required,
+// validation and the description in the desc (or usage) tag.
+func (c *ConfigReader) GenerateDocs(confPtr interface{}, format string) ([]byte, error) {
+	err := checkStructPtr(confPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*docsRow
+	ref := reflect.ValueOf(confPtr).Elem()
+	_ = walkThroughStruct("", ref, func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+		rows = append(rows, c.docsRow(fieldKey, structField))
+		return nil
+	})
+
+	switch strings.ToLower(format) {
+	case DocsMarkdown, "md":
+		return markdownDocs(rows), nil
+	case DocsHTML:
+		return htmlDocs(rows), nil
+	}
+	return nil, fmt.Errorf("docs format [%s] is not supported", format)
+}
+
+func (c *ConfigReader) docsRow(fieldKey string, structField reflect.StructField) *docsRow {
+	tag := structField.Tag
+
+	row := &docsRow{
+		key:         fieldKey,
+		typ:         structField.Type.String(),
+		defval:      tag.Get(tagDefault),
+		envs:        c.envNames(fieldKey, structField),
+		validation:  tag.Get(tagValidation),
+		description: tag.Get(tagDesc),
+	}
+	if row.description == "" {
+		row.description = tag.Get(tagUsage)
+	}
+	if flagname := tag.Get(tagFlag); flagname != "" {
+		row.flag = "--" + flagname
+	}
+
+	switch required := tag.Get(tagRequired); required {
+	case "":
+	case "true":
+		row.required = "yes"
+	default:
+		row.required = required
+	}
+	return row
+}
+
+func markdownDocs(rows []*docsRow) []byte {
+	buf := new(bytes.Buffer)
+
+	buf.WriteString("| " + strings.Join(docsColumns, " | ") + " |\n")
+	buf.WriteString("|" + strings.Repeat(" --- |", len(docsColumns)) + "\n")
+
+	code := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "`" + markdownEscape(s) + "`"
+	}
+	for _, row := range rows {
+		envs := make([]string, 0, len(row.envs))
+		for _, env := range row.envs {
+			envs = append(envs, code(env))
+		}
+
+		cells := []string{
+			code(row.key),
+			code(row.typ),
+			code(row.defval),
+			strings.Join(envs, ", "),
+			code(row.flag),
+			markdownEscape(row.required),
+			code(row.validation),
+			markdownEscape(row.description),
+		}
+		buf.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	return buf.Bytes()
+}
+
+// markdownEscape escapes the pipes, which break the table even in code spans
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func htmlDocs(rows []*docsRow) []byte {
+	buf := new(bytes.Buffer)
+
+	buf.WriteString("<table>\n<thead>\n<tr>")
+	for _, column := range docsColumns {
+		buf.WriteString("<th>" + column + "</th>")
+	}
+	buf.WriteString("</tr>\n</thead>\n<tbody>\n")
+
+	code := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "<code>" + html.EscapeString(s) + "</code>"
+	}
+	for _, row := range rows {
+		envs := make([]string, 0, len(row.envs))
+		for _, env := range row.envs {
+			envs = append(envs, code(env))
+		}
+
+		cells := []string{
+			code(row.key),
+			code(row.typ),
+			code(row.defval),
+			strings.Join(envs, ", "),
+			code(row.flag),
+			html.EscapeString(row.required),
+			code(row.validation),
+			html.EscapeString(row.description),
+		}
+		buf.WriteString("<tr>")
+		for _, cell := range cells {
+			buf.WriteString("<td>" + cell + "</td>")
+		}
+		buf.WriteString("</tr>\n")
+	}
+
+	buf.WriteString("</tbody>\n</table>\n")
+	return buf.Bytes()
+}
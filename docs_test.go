@@ -0,0 +1,45 @@
+package configreader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateDocs(t *testing.T) {
+	defer testTearDown()
+
+	type DBConfig struct {
+		User string `required:"true" desc:"the user of <db>"`
+	}
+
+	type Conf struct {
+		Host    string        `flag:"host" env:"host" default:"localhost" usage:"the host to listen"`
+		Timeout time.Duration `default:"3s" validation:"range:[1s, 1m] | in:[0s]"`
+		Cert    string        `required:"if=tls"`
+		TLS     bool          `key:"tls"`
+		DB      DBConfig      `key:"db"`
+	}
+
+	SetEnvPrefix("svc")
+
+	data, err := GenerateDocs(&Conf{}, DocsMarkdown)
+	assert.Nil(t, err)
+	assert.Equal(t, "| Key | Type | Default | Env | Flag | Required | Validation | Description |\n"+
+		"| --- | --- | --- | --- | --- | --- | --- | --- |\n"+
+		"| `host` | `string` | `localhost` | `SVC_HOST`, `HOST` | `--host` |  |  | the host to listen |\n"+
+		"| `timeout` | `time.Duration` | `3s` | `SVC_TIMEOUT` |  |  | `range:[1s, 1m] \\| in:[0s]` |  |\n"+
+		"| `cert` | `string` |  | `SVC_CERT` |  | if=tls |  |  |\n"+
+		"| `tls` | `bool` |  | `SVC_TLS` |  |  |  |  |\n"+
+		"| `db.user` | `string` |  | `SVC_DB_USER` |  | yes |  | the user of <db> |\n", string(data))
+
+	data, err = GenerateDocs(&Conf{}, DocsHTML)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "<thead>\n<tr><th>Key</th><th>Type</th>")
+	assert.Contains(t, string(data), "<tr><td><code>db.user</code></td><td><code>string</code></td><td></td>"+
+		"<td><code>SVC_DB_USER</code></td><td></td><td>yes</td><td></td><td>the user of &lt;db&gt;</td></tr>\n")
+
+	_, err = GenerateDocs(&Conf{}, "pdf")
+	assert.EqualError(t, err, "docs format [pdf] is not supported")
+}
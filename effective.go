@@ -0,0 +1,113 @@
+package configreader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// DumpEffective wraps the global ConfigReader instance
+func DumpEffective(w io.Writer, format string, opts ...Option) error {
+	return c.DumpEffective(w, format, opts...)
+}
+
+// DumpEffective writes the effective config of the last load, merged from the config files, env vars, flags
+// and defaults, limited to the keys of the config struct. Use WithProvenance to comment every key with
+// where its value comes from, which is supported in all the formats but json and hcl.
+// The secrets are masked unless WithUnmaskedSecrets.
+func (c *ConfigReader) DumpEffective(w io.Writer, format string, opts ...Option) error {
+	if !stringInSlice(format, SupportedExts) {
+		return fmt.Errorf("config type [%s] is not supported", format)
+	}
+	if len(c.fields) == 0 {
+		return fmt.Errorf("no config is loaded")
+	}
+
+	o := newOptions(opts)
+
+	settings := make(map[string]interface{})
+	var comments map[string]string
+	if o.provenance {
+		comments = make(map[string]string)
+	}
+	for _, p := range c.Explain() {
+		if p.Source == nil || p.Value == nil {
+			continue
+		}
+
+		// the raw values of env vars and flags are strings, decode them as the fields
+		structField := c.fields[p.Key]
+		value, err := decodeValue(p.Value, structField.Type)
+		if err != nil {
+			if c.isSecretField(p.Key, structField) {
+				return fmt.Errorf("[%s] unable to decode value", p.Key)
+			}
+			return fmt.Errorf("[%s] unable to decode value (%v): %v", p.Key, p.Value, err)
+		}
+		if value != nil {
+			plain := plainValue(reflect.ValueOf(value), !o.unmasked)
+			if !o.unmasked && c.isSecretField(p.Key, structField) {
+				plain = maskValue(plain)
+			}
+			setSetting(settings, p.Key, plain)
+		}
+
+		if comments != nil {
+			comments[p.Key] = "from " + p.Source.String()
+		}
+	}
+
+	return marshalWriter(w, format, settings, comments)
+}
+
+// commentedWriter writes the settings in yaml or toml with the comments above the keys
+func commentedWriter(w io.Writer, configType string, settings map[string]interface{}, comments map[string]string) error {
+	root := commentedNode("", "", settings, comments)
+
+	var err error
+	buf := new(bytes.Buffer)
+	if configType == "toml" {
+		err = writeTOMLExample(buf, root, "")
+	} else {
+		err = writeYAMLExample(buf, root.children, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// commentedNode builds the node of the settings sorted by the keys, the keys with comments are values,
+// e.g. maps, and the others are the parents of them
+func commentedNode(name string, key string, value interface{}, comments map[string]string) *exampleNode {
+	node := &exampleNode{name: name}
+	if comment, ok := comments[key]; ok {
+		node.comments = []string{comment}
+	}
+
+	settings, ok := value.(map[string]interface{})
+	if !ok || node.comments != nil {
+		node.value = value
+		return node
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	node.children = make([]*exampleNode, 0, len(names))
+	for _, name := range names {
+		childKey := name
+		if key != "" {
+			childKey = key + "." + name
+		}
+		node.children = append(node.children, commentedNode(name, childKey, settings[name], comments))
+	}
+	return node
+}
@@ -0,0 +1,144 @@
+package configreader
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpEffective(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	err := writeFile(fs, "/tmp/config.yaml", []byte(`host: base.local
+port: 80
+unknown: 1
+db:
+  user: app
+labels:
+  zone: us-east
+`))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/tmp/config_dev.yaml", []byte(`db:
+  user: dev
+`))
+	assert.Nil(t, err)
+
+	type DBConfig struct {
+		User string `key:"user"`
+		Name string `key:"name" default:"app"`
+	}
+
+	type Conf struct {
+		Host   string            `key:"host"`
+		Port   int               `key:"port" env:"port"`
+		Tags   []string          `key:"tags" env:"tags"`
+		Labels map[string]string `key:"labels"`
+		DB     DBConfig          `key:"db"`
+		Unset  string            `key:"unset"`
+	}
+
+	buf := new(bytes.Buffer)
+	err = DumpEffective(buf, "yaml")
+	assert.EqualError(t, err, "no config is loaded")
+
+	os.Setenv("APP_PORT", "8080")
+	defer os.Unsetenv("APP_PORT")
+	os.Setenv("APP_TAGS", "a,b")
+	defer os.Unsetenv("APP_TAGS")
+
+	AddConfigPath("/tmp")
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+
+	err = DumpEffective(buf, "yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, `db:
+  name: app
+  user: dev
+host: base.local
+labels:
+  zone: us-east
+port: 8080
+tags:
+- a
+- b
+`, buf.String())
+
+	buf.Reset()
+	err = DumpEffective(buf, "yaml", WithProvenance())
+	assert.Nil(t, err)
+	assert.Equal(t, `db:
+  # from default
+  name: app
+  # from file /tmp/config_dev.yaml:2:3
+  user: dev
+# from file /tmp/config.yaml:1:1
+host: base.local
+# from file /tmp/config.yaml:6:1
+labels: {"zone":"us-east"}
+# from env APP_PORT
+port: 8080
+# from env APP_TAGS
+tags: ["a","b"]
+`, buf.String())
+
+	// the effective config is the same as the loaded one
+	Reset()
+	SetFs(fs)
+	err = writeFile(fs, "/etc/config.yaml", buf.Bytes())
+	assert.Nil(t, err)
+	actual := Conf{}
+	err = ReadFromFile("/etc/config.yaml", &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, conf, actual)
+
+	Reset()
+	SetFs(fs)
+	AddConfigPath("/tmp")
+	err = LoadConfig(&Conf{})
+	assert.Nil(t, err)
+
+	buf.Reset()
+	err = DumpEffective(buf, "toml", WithProvenance())
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "# from env APP_PORT\nport = 8080\n")
+	assert.Contains(t, buf.String(), "\n[db]\n# from default\nname = \"app\"\n")
+
+	buf.Reset()
+	err = DumpEffective(buf, "properties", WithProvenance())
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "# from file /tmp/config.yaml:6:1\nlabels.zone = us-east\n")
+
+	buf.Reset()
+	err = DumpEffective(buf, "env", WithProvenance())
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "# from env APP_TAGS\ntags=\"a,b\"\n")
+
+	err = DumpEffective(buf, "ini", WithProvenance())
+	assert.EqualError(t, err, "[host] the top-level keys could not be read back from ini, put them in a table")
+
+	type DBConf struct {
+		DB DBConfig `key:"db"`
+	}
+
+	Reset()
+	SetFs(fs)
+	AddConfigPath("/tmp")
+	err = LoadConfig(&DBConf{})
+	assert.Nil(t, err)
+
+	buf.Reset()
+	err = DumpEffective(buf, "ini", WithProvenance())
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "[db]\n# from default\nname = app\n")
+
+	err = DumpEffective(buf, "json", WithProvenance())
+	assert.EqualError(t, err, "comments are not supported in config type [json]")
+}
@@ -0,0 +1,127 @@
+package configreader
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/afero"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// encPrefix and encSuffix wrap the encrypted values in config files, e.g. password: ENC[...]
+	encPrefix = "ENC["
+	encSuffix = "]"
+)
+
+// ErrNoDecryptionKey is returned when there are encrypted values but neither key nor key file is set
+var ErrNoDecryptionKey = xerrors.New("no decryption key is set")
+
+// SetDecryptionKey wraps the global ConfigReader instance
+func SetDecryptionKey(key []byte) { c.SetDecryptionKey(key) }
+
+// SetDecryptionKey sets the AES key, 16, 24 or 32 bytes, to decrypt the ENC[...] values in config files
+// and to encrypt the values by Encrypt, it takes the place of the key file
+func (c *ConfigReader) SetDecryptionKey(key []byte) {
+	c.decryptionKey = append([]byte(nil), key...)
+	c.decryptionKeyFile = ""
+}
+
+// SetDecryptionKeyFile wraps the global ConfigReader instance
+func SetDecryptionKeyFile(path string) { c.SetDecryptionKeyFile(path) }
+
+// SetDecryptionKeyFile sets the file holding the base64 encoded AES key, which is read through the filesystem
+// set by SetFs when it's needed, it takes the place of the key set by SetDecryptionKey
+func (c *ConfigReader) SetDecryptionKeyFile(path string) {
+	c.decryptionKeyFile = path
+	c.decryptionKey = nil
+}
+
+// Encrypt wraps the global ConfigReader instance
+func Encrypt(value string) (string, error) { return c.Encrypt(value) }
+
+// Encrypt encrypts the value by AES-GCM with the key set by SetDecryptionKey or SetDecryptionKeyFile,
+// it returns ENC[...] to put into config files, which is decrypted when loading them
+func (c *ConfigReader) Encrypt(value string) (string, error) {
+	key, err := c.loadDecryptionKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("unable to generate the nonce: %v", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed) + encSuffix, nil
+}
+
+// decrypt decrypts the ENC[...] value with the key of the reader
+func (c *ConfigReader) decrypt(value string) (string, error) {
+	key, err := c.loadDecryptionKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(value, encPrefix), encSuffix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %v", err)
+	}
+	if len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted value: too short")
+	}
+
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("wrong key or corrupted value: %v", err)
+	}
+	return string(plain), nil
+}
+
+// loadDecryptionKey returns the key set by SetDecryptionKey, or reads it from the key file
+func (c *ConfigReader) loadDecryptionKey() ([]byte, error) {
+	if c.decryptionKey != nil {
+		return c.decryptionKey, nil
+	}
+	if c.decryptionKeyFile == "" {
+		return nil, ErrNoDecryptionKey
+	}
+
+	data, err := afero.ReadFile(c.fs, c.decryptionKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the key file: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", c.decryptionKeyFile, err)
+	}
+	return key, nil
+}
+
+// newAEAD creates the AES-GCM cipher of the key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// isEncrypted tells if the value is an ENC[...] value
+func isEncrypted(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && len(s) > len(encPrefix) && strings.HasPrefix(s, encPrefix) && strings.HasSuffix(s, encSuffix)
+}
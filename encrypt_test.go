@@ -0,0 +1,132 @@
+package configreader
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+)
+
+func TestEncrypt(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	_, err := Encrypt("p@ss")
+	assert.True(t, xerrors.Is(err, ErrNoDecryptionKey))
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	SetDecryptionKey(key)
+	password, err := Encrypt("p@ss")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(password, "ENC["))
+	assert.NotContains(t, password, "p@ss")
+	other, err := Encrypt("p@ss")
+	assert.Nil(t, err)
+	assert.NotEqual(t, password, other)
+	token, err := Encrypt("t0ken")
+	assert.Nil(t, err)
+
+	err = writeFile(fs, "/tmp/config.yaml", []byte("user: app\ndb:\n  password: "+password+"\ntoken: "+token+"\n"))
+	assert.Nil(t, err)
+
+	type DBConfig struct {
+		Password string `key:"password"`
+	}
+
+	type Conf struct {
+		User  string   `key:"user"`
+		DB    DBConfig `key:"db"`
+		Token string   `key:"token"`
+	}
+
+	AddConfigPath("/tmp")
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+	assert.Equal(t, Conf{User: "app", DB: DBConfig{Password: "p@ss"}, Token: "t0ken"}, conf)
+
+	// the decrypted values are secrets
+	assert.Equal(t, "db.password = ****** from file /tmp/config.yaml:3:3", Provenance("db.password").String())
+	out := captureStdout(t, func() { PrintConfig(&conf) })
+	assert.Equal(t, "user: app\ndb.password: ******\ntoken: ******\n", out)
+	buf := new(bytes.Buffer)
+	err = DumpConfigTo(buf, "yaml", &conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "db:\n  password: '******'\ntoken: '******'\nuser: app\n", buf.String())
+
+	// the key file
+	err = writeFile(fs, "/etc/key", []byte(base64.StdEncoding.EncodeToString(key)+"\n"))
+	assert.Nil(t, err)
+
+	Reset()
+	SetFs(fs)
+	SetDecryptionKeyFile("/etc/key")
+	AddConfigPath("/tmp")
+	conf = Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "p@ss", conf.DB.Password)
+
+	// the failures are reported per key
+	Reset()
+	SetFs(fs)
+	AddConfigPath("/tmp")
+	err = LoadConfig(&Conf{})
+	assert.EqualError(t, err, "2 config errors occurred: "+
+		"[db.password] unable to decrypt the value from file /tmp/config.yaml:3:3: no decryption key is set; "+
+		"[token] unable to decrypt the value from file /tmp/config.yaml:4:1: no decryption key is set")
+	assert.True(t, xerrors.Is(err, ErrNoDecryptionKey))
+	var errs ValidationErrors
+	assert.True(t, xerrors.As(err, &errs))
+	assert.Equal(t, "db.password", errs[0].Key)
+	assert.Equal(t, "ENC", errs[0].Rule)
+
+	err = writeFile(fs, "/tmp/config.yaml", []byte("db:\n  password: "+password+"\ntoken: ENC[!!]\n"))
+	assert.Nil(t, err)
+
+	Reset()
+	SetFs(fs)
+	SetDecryptionKey([]byte("fedcba9876543210fedcba9876543210"))
+	AddConfigPath("/tmp")
+	err = LoadConfig(&Conf{})
+	assert.Contains(t, err.Error(), "[db.password] unable to decrypt the value from file /tmp/config.yaml:2:3: wrong key or corrupted value")
+	assert.Contains(t, err.Error(), "[token] unable to decrypt the value from file /tmp/config.yaml:3:1: invalid encrypted value")
+
+	// ValidateFile checks the decrypted values, and reports the ones which could not be decrypted
+	type ValidatedConf struct {
+		User  string `key:"user"`
+		Token string `key:"token" validation:"len:[8,]"`
+	}
+
+	err = writeFile(fs, "/etc/app/config.yaml", []byte("user: app\ntoken: "+token+"\n"))
+	assert.Nil(t, err)
+
+	Reset()
+	SetFs(fs)
+	SetDecryptionKey(key)
+	issues := ValidateFile("/etc/app/config.yaml", &ValidatedConf{})
+	assert.Equal(t, []Issue{{File: "/etc/app/config.yaml", Line: 2, Column: 1, Key: "token",
+		Message: "[token] did not pass validation [len:[8,]]. real [******]"}}, issues)
+
+	Reset()
+	SetFs(fs)
+	issues = ValidateFile("/etc/app/config.yaml", &ValidatedConf{})
+	assert.Equal(t, []Issue{{File: "/etc/app/config.yaml", Line: 2, Column: 1, Key: "token",
+		Message: "[token] unable to decrypt the value from file /etc/app/config.yaml:2:1: no decryption key is set"}}, issues)
+
+	Reset()
+	SetFs(fs)
+	SetDecryptionKeyFile("/etc/missing")
+	_, err = Encrypt("p@ss")
+	assert.EqualError(t, err, "unable to read the key file: open /etc/missing: file does not exist")
+
+	SetDecryptionKey([]byte("short"))
+	_, err = Encrypt("p@ss")
+	assert.EqualError(t, err, "invalid key: crypto/aes: invalid key size 5")
+}
@@ -0,0 +1,103 @@
+package configreader
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// FieldError describes a single field of the config struct that failed a check
+type FieldError struct {
+	// Key is the full key path of the field, e.g. 'server.port'
+	Key string
+	// Rule is the tag rule that failed, e.g. `required:"true"`
+	Rule string
+	// Value is the resolved value of the field, nil if it is not set
+	Value interface{}
+	// Source is where the value came from, e.g. 'env APP_PORT', empty if it is not set
+	Source string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors collects all the failed fields of a config struct.
+// Use errors.As to inspect the failures one by one.
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	msgs := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		msgs = append(msgs, fieldErr.Error())
+	}
+	return fmt.Sprintf("%d config errors occurred: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Is reports whether any of the failures matches the target
+func (e ValidationErrors) Is(target error) bool {
+	for _, fieldErr := range e {
+		if xerrors.Is(fieldErr, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first failure that matches the target, and sets target to it
+func (e ValidationErrors) As(target interface{}) bool {
+	for _, fieldErr := range e {
+		if xerrors.As(fieldErr, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownKeyError is reported in strict mode for the key in a config file
+// which has no matching field in the config struct
+type UnknownKeyError struct {
+	Key string
+	// File is the config file where the key is defined, empty if it's read from io.Reader
+	File string
+	// Line and Column are the position of the key in the file, 0 if unknown
+	Line   int
+	Column int
+	// Suggestion is the most similar key of the config struct, empty if there is none
+	Suggestion string
+}
+
+func (e *UnknownKeyError) Error() string {
+	location := ""
+	if e.File != "" {
+		location = e.File
+		if e.Line > 0 {
+			location += fmt.Sprintf(":%d:%d", e.Line, e.Column)
+		}
+	}
+	return e.message(location)
+}
+
+// message describes the error with the location of the key, which is omitted if it's empty
+func (e *UnknownKeyError) message(location string) string {
+	msg := fmt.Sprintf("unknown config key [%s]", e.Key)
+	if location != "" {
+		msg += " in " + location
+	}
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean [%s]?", e.Suggestion)
+	}
+	return msg
+}
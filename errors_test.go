@@ -0,0 +1,150 @@
+package configreader
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrors(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	configData := []byte(`{
+		"name": "toolong",
+		"level": "debug"
+	}`)
+	err := writeFile(fs, "/tmp/config.json", configData)
+	assert.Nil(t, err)
+
+	type Conf struct {
+		Host  string `required:"true"`
+		Name  string `validation:"range:[1, 4]"`
+		Level string `validation:"in:[info, error]"`
+		Port  int    `env:"port" validation:"range:[80, 90]"`
+		Ok    int    `default:"1" validation:"in:[1]"`
+	}
+
+	os.Setenv("APP_PORT", "8080")
+	defer os.Unsetenv("APP_PORT")
+
+	SetConfigName("config")
+	AddConfigPath("/tmp")
+
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.NotNil(t, err)
+
+	var verrs ValidationErrors
+	assert.True(t, errors.As(err, &verrs))
+	assert.Len(t, verrs, 4)
+	assert.Contains(t, err.Error(), "4 config errors occurred")
+
+	var fieldErr *FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "host", fieldErr.Key)
+	assert.True(t, errors.Is(err, verrs[2]))
+
+	assert.Equal(t, "host", verrs[0].Key)
+	assert.Equal(t, `required:"true"`, verrs[0].Rule)
+	assert.Nil(t, verrs[0].Value)
+	assert.Equal(t, "", verrs[0].Source)
+
+	assert.Equal(t, "name", verrs[1].Key)
+	assert.Equal(t, `validation:"range:[1, 4]"`, verrs[1].Rule)
+	assert.Equal(t, "toolong", verrs[1].Value)
+	assert.Equal(t, "file /tmp/config.json:2:3", verrs[1].Source)
+
+	assert.Equal(t, "level", verrs[2].Key)
+
+	assert.Equal(t, "port", verrs[3].Key)
+	assert.Equal(t, "8080", verrs[3].Value)
+	assert.Equal(t, "env APP_PORT", verrs[3].Source)
+}
+
+type hookTLSConfig struct {
+	Cert string
+	Key  string
+}
+
+func (c *hookTLSConfig) Validate() error {
+	if (c.Cert == "") != (c.Key == "") {
+		return errors.New("cert and key must be set together")
+	}
+	return nil
+}
+
+type hookConfig struct {
+	Min  int           `validation:"range:[0, 10]"`
+	Max  int           `validation:"range:[0, 10]"`
+	TLS  hookTLSConfig `key:"tls"`
+	Peer hookTLSConfig
+}
+
+func (c hookConfig) Validate() error {
+	if c.Min > c.Max {
+		return errors.New("min is greater than max")
+	}
+	return nil
+}
+
+func TestValidateHooks(t *testing.T) {
+	defer testTearDown()
+
+	configData := []byte(`{
+		"min": "8",
+		"max": "20",
+		"tls": {
+			"cert": "/etc/tls.crt"
+		},
+		"peer": {
+			"cert": "/etc/tls.crt",
+			"key": "/etc/tls.key"
+		}
+	}`)
+
+	conf := hookConfig{}
+	err := ReadConfig(bytes.NewBuffer(configData), "json", &conf)
+	assert.NotNil(t, err)
+
+	var verrs ValidationErrors
+	assert.ErrorAs(t, err, &verrs)
+	assert.Len(t, verrs, 2)
+	assert.Equal(t, "max", verrs[0].Key)
+	assert.Equal(t, "tls", verrs[1].Key)
+	assert.Equal(t, "Validate()", verrs[1].Rule)
+	assert.Equal(t, "[tls] is invalid: cert and key must be set together", verrs[1].Error())
+
+	// the struct is untouched if the validation failed
+	assert.Equal(t, hookConfig{}, conf)
+
+	conf = hookConfig{}
+	err = ReadConfig(bytes.NewBufferString(`{"min": "8", "max": "2"}`), "json", &conf)
+	assert.NotNil(t, err)
+	assert.Equal(t, "config is invalid: min is greater than max", err.Error())
+
+	// neither the maps nor the pointers in the struct are changed if the validation failed
+	type MapConf struct {
+		Port   int               `key:"port" validation:"range:[1, 10]"`
+		Labels map[string]string `key:"labels"`
+		Tags   *[]string         `key:"tags"`
+	}
+
+	tags := []string{"orig"}
+	mapConf := MapConf{Labels: map[string]string{"a": "orig"}, Tags: &tags}
+	err = ReadConfig(bytes.NewBufferString(`{"port": 80, "labels": {"a": "changed", "b": "new"}, "tags": ["changed"]}`), "json", &mapConf)
+	assert.EqualError(t, err, "[port] did not pass validation [range:[1, 10]]. real [80]")
+	assert.Equal(t, map[string]string{"a": "orig"}, mapConf.Labels)
+	assert.Equal(t, []string{"orig"}, tags)
+
+	err = ReadConfig(bytes.NewBufferString(`{"port": 8, "labels": {"a": "changed", "b": "new"}}`), "json", &mapConf)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "changed", "b": "new"}, mapConf.Labels)
+	assert.Equal(t, 8, mapConf.Port)
+}
@@ -0,0 +1,369 @@
+package configreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// exampleNode is one key of the example config, either a field or a parent of fields
+type exampleNode struct {
+	name     string
+	comments []string
+	value    interface{}
+	children []*exampleNode
+	// commented tells to comment out the key, so the placeholder of a required key doesn't satisfy it
+	commented bool
+}
+
+func (n *exampleNode) child(name string) *exampleNode {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	child := &exampleNode{name: name}
+	n.children = append(n.children, child)
+	return child
+}
+
+// GenerateExample wraps the global ConfigReader instance
+func GenerateExample(confPtr interface{}, format string) ([]byte, error) {
+	return c.GenerateExample(confPtr, format)
+}
+
+// GenerateExample generates an example config file of the struct in yaml, toml or json5, all the keys
+// are set with the defaults in the default tags or the zero values, and commented with the description,
+// the validation and the env vars and flag to override it. The required keys without defaults are
+// commented out, so the config copied from the example still fails until they are set.
+func (c *ConfigReader) GenerateExample(confPtr interface{}, format string) ([]byte, error) {
+	err := checkStructPtr(confPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	root := new(exampleNode)
+	ref := reflect.ValueOf(confPtr).Elem()
+	err = walkThroughStruct("", ref, func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+		node := root
+		for _, name := range strings.Split(fieldKey, ".") {
+			node = node.child(name)
+		}
+
+		node.value, err = exampleValue(fieldKey, structField)
+		node.comments = c.exampleComments(fieldKey, structField)
+		node.commented = structField.Tag.Get(tagRequired) != "" && structField.Tag.Get(tagDefault) == ""
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		err = writeYAMLExample(buf, root.children, "")
+	case "toml":
+		err = writeTOMLExample(buf, root, "")
+	case "json5":
+		err = writeJSON5Example(buf, root, "")
+		buf.WriteString("\n")
+	default:
+		return nil, fmt.Errorf("example format [%s] is not supported", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exampleValue returns the default of the field, or the zero value if there is none
+func exampleValue(fieldKey string, structField reflect.StructField) (interface{}, error) {
+	defval := structField.Tag.Get(tagDefault)
+
+	val, err := flagDefault(structField.Type, defval)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] unable to parse default (%s): %v", fieldKey, defval, err)
+	}
+	return plainValue(val, false), nil
+}
+
+// plainValue converts the value into the plain types to encode, durations are strings,
+// structs are maps by their keys, and nil slices and maps are empty ones. mask tells to mask the secrets.
+func plainValue(ref reflect.Value, mask bool) interface{} {
+	switch ref.Type() {
+	case durationType:
+		return time.Duration(ref.Int()).String()
+	case secretType:
+		if mask {
+			return maskValue(ref.String())
+		}
+		return ref.String()
+	}
+
+	switch ref.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if ref.IsNil() {
+			return ""
+		}
+		return plainValue(ref.Elem(), mask)
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, 0, ref.Len())
+		for i := 0; i < ref.Len(); i++ {
+			items = append(items, plainValue(ref.Index(i), mask))
+		}
+		return items
+	case reflect.Map:
+		items := make(map[string]interface{}, ref.Len())
+		for _, key := range ref.MapKeys() {
+			items[fmt.Sprint(key)] = plainValue(ref.MapIndex(key), mask)
+		}
+		return items
+	case reflect.Struct:
+		items := make(map[string]interface{})
+		_ = walkThroughStruct("", ref, func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+			setSetting(items, fieldKey, fieldValue(structField, structRef, mask))
+			return nil
+		})
+		return items
+	}
+	return ref.Interface()
+}
+
+// fieldValue is the plain value of the field, masked if it's a secret and mask is true
+func fieldValue(structField reflect.StructField, structRef reflect.Value, mask bool) interface{} {
+	value := plainValue(structRef, mask)
+	if mask && isSecret(structField) {
+		return maskValue(value)
+	}
+	return value
+}
+
+// setSetting sets the value of the dotted key into the nested maps of the settings
+func setSetting(settings map[string]interface{}, key string, value interface{}) {
+	names := strings.Split(key, ".")
+	parent := settings
+	for _, name := range names[:len(names)-1] {
+		child, ok := parent[name].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			parent[name] = child
+		}
+		parent = child
+	}
+	parent[names[len(names)-1]] = value
+}
+
+func (c *ConfigReader) exampleComments(fieldKey string, structField reflect.StructField) []string {
+	tag := structField.Tag
+
+	var comments []string
+	if desc := tag.Get(tagDesc); desc != "" {
+		comments = append(comments, desc)
+	} else if usage := tag.Get(tagUsage); usage != "" {
+		comments = append(comments, usage)
+	}
+
+	switch required := tag.Get(tagRequired); required {
+	case "":
+	case "true":
+		comments = append(comments, "required")
+	default:
+		comments = append(comments, "required: "+required)
+	}
+	if validation := tag.Get(tagValidation); validation != "" {
+		comments = append(comments, "validation: "+validation)
+	}
+
+	overrides := "env: " + strings.Join(c.envNames(fieldKey, structField), ", ")
+	if flagname := tag.Get(tagFlag); flagname != "" {
+		overrides += ", flag: --" + flagname
+	}
+	return append(comments, overrides)
+}
+
+// jsonValue encodes the value in json, which is valid as the flow style of yaml as well
+func jsonValue(value interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func writeComments(buf *bytes.Buffer, indent string, sign string, comments []string) {
+	for _, comment := range comments {
+		buf.WriteString(indent + sign + " " + comment + "\n")
+	}
+}
+
+// commentOut returns the prefix to comment out the key of the node if it should be
+func commentOut(node *exampleNode, sign string) string {
+	if node.commented {
+		return sign + " "
+	}
+	return ""
+}
+
+////////
+
+func writeYAMLExample(buf *bytes.Buffer, nodes []*exampleNode, indent string) error {
+	for _, node := range nodes {
+		writeComments(buf, indent, "#", node.comments)
+
+		if node.children != nil {
+			buf.WriteString(indent + node.name + ":\n")
+			if err := writeYAMLExample(buf, node.children, indent+"  "); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, err := yamlValue(node.value)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(indent + commentOut(node, "#") + node.name + ": " + value + "\n")
+	}
+	return nil
+}
+
+func yamlValue(value interface{}) (string, error) {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return jsonValue(value)
+	}
+
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+////////
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	s, _ := jsonValue(key)
+	return s
+}
+
+// writeTOMLExample writes the values of the node first, and then its children as tables
+func writeTOMLExample(buf *bytes.Buffer, node *exampleNode, table string) error {
+	var tables []*exampleNode
+	for _, child := range node.children {
+		if child.children != nil {
+			tables = append(tables, child)
+			continue
+		}
+
+		writeComments(buf, "", "#", child.comments)
+		value, err := tomlValue(child.value)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(commentOut(child, "#") + tomlKey(child.name) + " = " + value + "\n")
+	}
+
+	for _, child := range tables {
+		name := tomlKey(child.name)
+		if table != "" {
+			name = table + "." + name
+		}
+
+		buf.WriteString("\n[" + name + "]\n")
+		if err := writeTOMLExample(buf, child, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tomlValue encodes the plain value, arrays and inline tables are in one line
+func tomlValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, elem := range v {
+			item, err := tomlValue(elem)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		items := make([]string, 0, len(keys))
+		for _, key := range keys {
+			item, err := tomlValue(v[key])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(key)+" = "+item)
+		}
+		if len(items) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	}
+
+	return jsonValue(value)
+}
+
+////////
+
+func writeJSON5Example(buf *bytes.Buffer, node *exampleNode, indent string) error {
+	// the commas are only between the keys not commented out
+	last := -1
+	for i, child := range node.children {
+		if !child.commented {
+			last = i
+		}
+	}
+
+	buf.WriteString("{\n")
+	for i, child := range node.children {
+		writeComments(buf, indent+"  ", "//", child.comments)
+
+		name, _ := jsonValue(child.name)
+		buf.WriteString(indent + "  " + commentOut(child, "//") + name + ": ")
+		if child.children != nil {
+			if err := writeJSON5Example(buf, child, indent+"  "); err != nil {
+				return err
+			}
+		} else {
+			value, err := jsonValue(child.value)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(value)
+		}
+
+		if !child.commented && i < last {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString(indent + "}")
+	return nil
+}
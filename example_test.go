@@ -0,0 +1,112 @@
+package configreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+)
+
+func TestGenerateExample(t *testing.T) {
+	defer testTearDown()
+
+	type Upstream struct {
+		Addr   string `key:"addr"`
+		Weight int    `key:"weight"`
+	}
+
+	type DBConfig struct {
+		User string `key:"user" required:"true" desc:"the user of db"`
+		Port int    `key:"port" default:"5432" validation:"range:[1,65535]"`
+	}
+
+	type Conf struct {
+		Host      string            `key:"host" flag:"host" env:"host" default:"localhost" usage:"the host to listen"`
+		Timeout   time.Duration     `key:"timeout" default:"3s"`
+		Tags      []string          `key:"tags" default:"a,b"`
+		Labels    map[string]string `key:"labels"`
+		Upstreams []Upstream        `key:"upstreams"`
+		DB        DBConfig          `key:"db"`
+	}
+
+	SetEnvPrefix("svc")
+
+	data, err := GenerateExample(&Conf{}, "yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, `# the host to listen
+# env: SVC_HOST, HOST, flag: --host
+host: localhost
+# env: SVC_TIMEOUT
+timeout: 3s
+# env: SVC_TAGS
+tags: ["a","b"]
+# env: SVC_LABELS
+labels: {}
+# env: SVC_UPSTREAMS
+upstreams: []
+db:
+  # the user of db
+  # required
+  # env: SVC_DB_USER
+  # user: ""
+  # validation: range:[1,65535]
+  # env: SVC_DB_PORT
+  port: 5432
+`, string(data))
+
+	// the example is a valid config file of the struct, but the required keys are left to set
+	Reset()
+	fs := afero.NewMemMapFs()
+	SetFs(fs)
+	err = writeFile(fs, "/etc/config.yaml", data)
+	assert.Nil(t, err)
+	SetConfigPaths([]string{"/etc"})
+	SetEnvPrefix("svc")
+
+	conf := &Conf{}
+	err = LoadConfig(conf)
+	var fieldErr *FieldError
+	assert.True(t, xerrors.As(err, &fieldErr))
+	assert.Equal(t, "db.user", fieldErr.Key)
+	assert.Equal(t, `required:"true"`, fieldErr.Rule)
+
+	err = writeFile(fs, "/etc/config.yaml", bytes.Replace(data, []byte(`# user: ""`), []byte(`user: app`), 1))
+	assert.Nil(t, err)
+	err = LoadConfig(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, &Conf{
+		Host:      "localhost",
+		Timeout:   3 * time.Second,
+		Tags:      []string{"a", "b"},
+		Upstreams: []Upstream{},
+		DB:        DBConfig{User: "app", Port: 5432},
+	}, conf)
+
+	data, err = GenerateExample(&Conf{}, "json5")
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "{\n  // the host to listen\n  // env: SVC_HOST, HOST, flag: --host\n  \"host\": \"localhost\",\n")
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			lines = append(lines, line)
+		}
+	}
+	var values map[string]interface{}
+	err = json.Unmarshal([]byte(strings.Join(lines, "\n")), &values)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"port": float64(5432)}, values["db"])
+	assert.Contains(t, string(data), "  // \"user\": \"\"\n")
+
+	data, err = GenerateExample(&Conf{}, "toml")
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "upstreams = []\n\n[db]\n# the user of db\n")
+	assert.Contains(t, string(data), "# user = \"\"\n")
+
+	_, err = GenerateExample(&Conf{}, "ini")
+	assert.EqualError(t, err, "example format [ini] is not supported")
+}
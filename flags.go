@@ -0,0 +1,158 @@
+package configreader
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// tags for the flags registered by RegisterFlags
+const (
+	tagUsage = "usage"
+	tagDesc  = "desc"
+)
+
+// RegisterFlags wraps the global ConfigReader instance
+func RegisterFlags(fs *pflag.FlagSet, confPtr interface{}) error { return c.RegisterFlags(fs, confPtr) }
+
+// RegisterFlags creates the flags in the flag tags of the config struct, typed by the fields,
+// with the default tags as the defaults and the usage (or desc) tags as the usage text.
+// It should be called before fs.Parse, so that --help shows them. If the reader has no
+// flagset yet, fs is used to look up the flags when loading. If fs is nil, the flags are
+// created in the flagset of the reader, see FlagSet.
+func (c *ConfigReader) RegisterFlags(fs *pflag.FlagSet, confPtr interface{}) error {
+	err := checkStructPtr(confPtr)
+	if err != nil {
+		return err
+	}
+
+	if fs == nil {
+		fs = c.FlagSet()
+	}
+
+	ref := reflect.ValueOf(confPtr).Elem()
+	err = walkThroughStruct("", ref, func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+		if structField.Tag.Get(tagFlag) == "" {
+			return nil
+		}
+		return registerFlag(fs, fieldKey, structField)
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.flagset == nil {
+		c.flagset = fs
+	}
+	return nil
+}
+
+func registerFlag(fs *pflag.FlagSet, fieldKey string, structField reflect.StructField) error {
+	tag := structField.Tag
+	flagname := tag.Get(tagFlag)
+	if fs.Lookup(flagname) != nil {
+		return fmt.Errorf("flag [--%s] of [%s] is already defined", flagname, fieldKey)
+	}
+
+	usage := tag.Get(tagUsage)
+	if usage == "" {
+		usage = tag.Get(tagDesc)
+	}
+	if usage == "" {
+		usage = fieldKey
+	}
+
+	defval := tag.Get(tagDefault)
+	val, err := flagDefault(structField.Type, defval)
+	if err != nil {
+		return fmt.Errorf("unable to parse default (%s) of flag [--%s]: %w", defval, flagname, err)
+	}
+
+	addFlag(fs, flagname, structField.Type, val, defval, usage)
+	return nil
+}
+
+// flagDefault converts the default tag into the value of the field type,
+// slices and maps could be in json as LoadDefault does
+func flagDefault(typ reflect.Type, defval string) (reflect.Value, error) {
+	if defval == "" {
+		return reflect.Zero(typ), nil
+	}
+
+	kind := typ.Kind()
+	if (kind == reflect.Slice && strings.HasPrefix(defval, "[")) || (kind == reflect.Map && strings.HasPrefix(defval, "{")) {
+		ref := reflect.New(typ)
+		if err := json.Unmarshal([]byte(defval), ref.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return ref.Elem(), nil
+	}
+
+	val, err := decodeValue(defval, typ)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(val), nil
+}
+
+// addFlag creates the flag of the type, only the types which viper reads back from
+// the flags correctly are typed, the others are string flags in the format of env values
+func addFlag(fs *pflag.FlagSet, name string, typ reflect.Type, val reflect.Value, defval string, usage string) {
+	if typ == durationType {
+		fs.Duration(name, time.Duration(val.Int()), usage)
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		fs.String(name, val.String(), usage)
+	case reflect.Bool:
+		fs.Bool(name, val.Bool(), usage)
+	case reflect.Int:
+		fs.Int(name, int(val.Int()), usage)
+	case reflect.Int8:
+		fs.Int8(name, int8(val.Int()), usage)
+	case reflect.Int16:
+		fs.Int16(name, int16(val.Int()), usage)
+	case reflect.Int32:
+		fs.Int32(name, int32(val.Int()), usage)
+	case reflect.Int64:
+		fs.Int64(name, val.Int(), usage)
+	case reflect.Uint:
+		fs.Uint(name, uint(val.Uint()), usage)
+	case reflect.Uint8:
+		fs.Uint8(name, uint8(val.Uint()), usage)
+	case reflect.Uint16:
+		fs.Uint16(name, uint16(val.Uint()), usage)
+	case reflect.Uint32:
+		fs.Uint32(name, uint32(val.Uint()), usage)
+	case reflect.Uint64:
+		fs.Uint64(name, val.Uint(), usage)
+	case reflect.Float32:
+		fs.Float32(name, float32(val.Float()), usage)
+	case reflect.Float64:
+		fs.Float64(name, val.Float(), usage)
+	case reflect.Slice:
+		switch v := val.Convert(reflect.SliceOf(typ.Elem())).Interface().(type) {
+		case []string:
+			fs.StringSlice(name, v, usage)
+		case []int:
+			fs.IntSlice(name, v, usage)
+		default:
+			fs.String(name, defval, usage)
+		}
+	case reflect.Map:
+		switch v := val.Convert(reflect.MapOf(typ.Key(), typ.Elem())).Interface().(type) {
+		case map[string]string:
+			fs.StringToString(name, v, usage)
+		default:
+			fs.String(name, defval, usage)
+		}
+	default:
+		fs.String(name, defval, usage)
+	}
+}
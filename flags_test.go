@@ -0,0 +1,165 @@
+package configreader
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	defer testTearDown()
+
+	type Level string
+
+	type Conf struct {
+		Host    string            `flag:"host" default:"localhost" usage:"the host to listen"`
+		Port    uint16            `flag:"port" default:"80" desc:"the port to listen"`
+		Debug   bool              `flag:"debug"`
+		Level   Level             `flag:"level" default:"info"`
+		Timeout time.Duration     `flag:"timeout" default:"3s"`
+		Ratio   float64           `flag:"ratio" default:"0.5"`
+		Tags    []string          `flag:"tags" default:"a,b"`
+		Ports   []int             `flag:"ports" default:"8,10"`
+		Labels  map[string]string `flag:"labels" default:"{\"k\":\"v\"}"`
+		Delays  []time.Duration   `flag:"delays" default:"1s,2s"`
+		NoFlag  string
+	}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	conf := Conf{}
+	err := RegisterFlags(fs, &conf)
+	assert.Nil(t, err)
+
+	for name, typ := range map[string]string{
+		"host":    "string",
+		"port":    "uint16",
+		"debug":   "bool",
+		"level":   "string",
+		"timeout": "duration",
+		"ratio":   "float64",
+		"tags":    "stringSlice",
+		"ports":   "intSlice",
+		"labels":  "stringToString",
+		"delays":  "string",
+	} {
+		flag := fs.Lookup(name)
+		if assert.NotNil(t, flag, name) {
+			assert.Equal(t, typ, flag.Value.Type(), name)
+		}
+	}
+	assert.Equal(t, "the host to listen", fs.Lookup("host").Usage)
+	assert.Equal(t, "the port to listen", fs.Lookup("port").Usage)
+	assert.Equal(t, "debug", fs.Lookup("debug").Usage)
+	assert.Equal(t, "3s", fs.Lookup("timeout").DefValue)
+	assert.Equal(t, "[a,b]", fs.Lookup("tags").DefValue)
+	assert.Equal(t, "[8,10]", fs.Lookup("ports").DefValue)
+	assert.Equal(t, "[k=v]", fs.Lookup("labels").DefValue)
+	assert.Nil(t, fs.Lookup("noflag"))
+
+	err = fs.Parse([]string{"--port=8080", "--debug", "--timeout=1m", "--tags=x,y", "--labels=a=b", "--delays=3s,4s"})
+	assert.Nil(t, err)
+
+	err = ReadConfig(bytes.NewBufferString(`{}`), "json", &conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost", conf.Host)
+	assert.Equal(t, uint16(8080), conf.Port)
+	assert.True(t, conf.Debug)
+	assert.Equal(t, Level("info"), conf.Level)
+	assert.Equal(t, time.Minute, conf.Timeout)
+	assert.Equal(t, []string{"x", "y"}, conf.Tags)
+	assert.Equal(t, []int{8, 10}, conf.Ports)
+	assert.Equal(t, map[string]string{"a": "b"}, conf.Labels)
+	assert.Equal(t, []time.Duration{3 * time.Second, 4 * time.Second}, conf.Delays)
+
+	err = RegisterFlags(fs, &conf)
+	assert.EqualError(t, err, "flag [--host] of [host] is already defined")
+}
+
+func TestOwnFlagSet(t *testing.T) {
+	defer testTearDown()
+
+	type Conf struct {
+		Port  int    `flag:"port" default:"80"`
+		Level string `flag:"level"`
+	}
+
+	// readers do not share the flags, and loading twice does not redefine them
+	r1, r2 := New(), New()
+	for _, r := range []*ConfigReader{r1, r1, r2} {
+		conf := Conf{}
+		err := r.ReadConfig(bytes.NewBufferString(`{"level": "info"}`), "json", &conf)
+		assert.Nil(t, err)
+		assert.Equal(t, Conf{Port: 80, Level: "info"}, conf)
+	}
+	assert.Nil(t, pflag.Lookup("port"))
+	assert.Equal(t, "int", r1.FlagSet().Lookup("port").Value.Type())
+	assert.NotSame(t, r1.FlagSet(), r2.FlagSet())
+
+	err := r1.ParseFlags([]string{"--port=8080", "--unknown=1"})
+	assert.Nil(t, err)
+	conf := Conf{}
+	err = r1.ReadConfig(bytes.NewBufferString(`{}`), "json", &conf)
+	assert.Nil(t, err)
+	assert.Equal(t, 8080, conf.Port)
+
+	// borrowed flagsets are not changed
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	SetFlagSet(fs)
+	err = ReadConfig(bytes.NewBufferString(`{}`), "json", &conf)
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Lookup("port"))
+
+	type DupConf struct {
+		Level  string `flag:"level"`
+		Logger struct {
+			Level string `flag:"level"`
+		}
+	}
+	err = ReadConfig(bytes.NewBufferString(`{}`), "json", &DupConf{})
+	assert.EqualError(t, err, "flag [--level] is bound to both [level] and [logger.level]")
+}
+
+func TestAutoParseFlags(t *testing.T) {
+	defer testTearDown()
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"app", "--level=debug", "--other"}
+
+	type Conf struct {
+		Level string `flag:"level" default:"info"`
+	}
+
+	conf := Conf{}
+	err := ReadConfig(bytes.NewBufferString(`{}`), "json", &conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "info", conf.Level)
+
+	Reset()
+	SetAutoParseFlags(true)
+	err = ReadConfig(bytes.NewBufferString(`{}`), "json", &conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "debug", conf.Level)
+	assert.True(t, FlagSet().Parsed())
+
+	// the usage is printed for --help
+	os.Args = []string{"app", "--help"}
+	Reset()
+	SetAutoParseFlags(true)
+	usage := new(bytes.Buffer)
+	FlagSet().SetOutput(usage)
+	conf = Conf{}
+	err = ReadConfig(bytes.NewBufferString(`{}`), "json", &conf)
+	assert.Equal(t, ErrHelp, err)
+	assert.Contains(t, usage.String(), "--level string")
+	assert.Equal(t, Conf{}, conf)
+
+	usage.Reset()
+	err = ParseFlags([]string{"-h"})
+	assert.Equal(t, ErrHelp, err)
+	assert.Contains(t, usage.String(), "--level string")
+}
@@ -3,11 +3,18 @@ module github.com/go-srv/configreader
 go 1.13
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/hashicorp/hcl v1.0.0
+	github.com/magiconair/properties v1.8.5
 	github.com/mitchellh/mapstructure v1.4.1
+	github.com/pelletier/go-toml v1.9.3
 	github.com/spf13/afero v1.6.0
+	github.com/spf13/cobra v1.5.0
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.8.1
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
+	gopkg.in/ini.v1 v1.62.0
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -0,0 +1,63 @@
+package configreader
+
+import "os"
+
+// Option changes the behavior of printing and dumping configs
+type Option func(*options)
+
+type options struct {
+	provenance bool
+	unmasked   bool
+
+	// for dumping configs into files
+	overwrite bool
+	atomic    bool
+	// fileMode is set by WithFileMode, or 0644 for the new files
+	fileMode    os.FileMode
+	fileModeSet bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{fileMode: 0644}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithProvenance annotates the values with the sources they come from
+func WithProvenance() Option {
+	return func(o *options) {
+		o.provenance = true
+	}
+}
+
+// WithOverwrite overwrites the existing file when dumping configs
+func WithOverwrite() Option {
+	return func(o *options) {
+		o.overwrite = true
+	}
+}
+
+// WithAtomicWrite dumps configs into a temp file in the same directory and then renames it into place
+func WithAtomicWrite() Option {
+	return func(o *options) {
+		o.atomic = true
+	}
+}
+
+// WithFileMode sets the mode of the file dumped into, by default the new files are 0644
+// and the existing files keep their modes
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.fileMode = mode
+		o.fileModeSet = true
+	}
+}
+
+// WithUnmaskedSecrets shows the values of the secrets in plain text, which are masked by default
+func WithUnmaskedSecrets() Option {
+	return func(o *options) {
+		o.unmasked = true
+	}
+}
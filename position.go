@@ -0,0 +1,130 @@
+package configreader
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// position is where a key is defined in a config file, both line and column start from 1
+type position struct {
+	Line   int
+	Column int
+}
+
+// keyPositions finds the positions of keys in the config data, the keys are in lower case
+// as viper does. It's a best effort, keys of unsupported types or formats are not found.
+func keyPositions(configType string, data []byte) map[string]position {
+	switch configType {
+	case "yaml", "yml", "json":
+		return yamlKeyPositions(data)
+	case "toml", "ini", "properties", "props", "prop", "dotenv", "env":
+		return lineKeyPositions(configType, data)
+	}
+	return map[string]position{}
+}
+
+// yamlKeyPositions finds the keys of the first document as viper reads, it works for json as well,
+// which is valid yaml. The keys in sequences are not indexed by viper, and are skipped.
+func yamlKeyPositions(data []byte) map[string]position {
+	positions := make(map[string]position)
+
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return positions
+	}
+	for _, node := range doc.Content {
+		yamlNodePositions("", node, positions)
+	}
+	return positions
+}
+
+// yamlNodePositions finds the keys of the mapping node, the keys merged by << are
+// overridden by the ones defined in the mapping, and the earlier merged ones win
+func yamlNodePositions(prefix string, node *yaml.Node, positions map[string]position) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if keyNode, valueNode := node.Content[i], node.Content[i+1]; keyNode.Tag == "!!merge" {
+			merged := []*yaml.Node{valueNode}
+			if valueNode.Kind == yaml.SequenceNode {
+				merged = valueNode.Content
+			}
+			for j := len(merged) - 1; j >= 0; j-- {
+				yamlNodePositions(prefix, merged[j], positions)
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag == "!!merge" {
+			continue
+		}
+
+		key := prefix + strings.ToLower(keyNode.Value)
+		positions[key] = position{Line: keyNode.Line, Column: keyNode.Column}
+		yamlNodePositions(key+".", valueNode, positions)
+	}
+}
+
+// lineKeyPositions finds the keys of the formats which define one key per line
+func lineKeyPositions(configType string, data []byte) map[string]position {
+	positions := make(map[string]position)
+
+	separators := "="
+	switch configType {
+	case "ini":
+		separators = "=:"
+	case "properties", "props", "prop":
+		separators = "=: \t"
+	}
+
+	section := ""
+	skipSection := false
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "!") {
+			continue
+		}
+
+		// sections of toml and ini, keys of array tables are not indexed by viper
+		if (configType == "toml" || configType == "ini") && strings.HasPrefix(trimmed, "[") {
+			skipSection = strings.HasPrefix(trimmed, "[[")
+			section = strings.ToLower(strings.Trim(trimmed, "[] \t"))
+			continue
+		}
+		if skipSection {
+			continue
+		}
+
+		keyStart := strings.Index(line, trimmed)
+		if configType == "dotenv" || configType == "env" {
+			if strings.HasPrefix(trimmed, "export ") {
+				trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "export "))
+				keyStart = strings.Index(line, trimmed)
+			}
+		}
+
+		idx := strings.IndexAny(trimmed, separators)
+		if idx <= 0 {
+			continue
+		}
+
+		key := strings.ToLower(strings.Trim(strings.TrimSpace(trimmed[:idx]), `"'`))
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, ok := positions[key]; !ok {
+			positions[key] = position{Line: i + 1, Column: keyStart + 1}
+		}
+	}
+
+	return positions
+}
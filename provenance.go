@@ -0,0 +1,181 @@
+package configreader
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// SourceKind is the kind of layer which sets a value
+type SourceKind string
+
+// The kinds of Source, in the order of priority
+const (
+	SourceFlag        SourceKind = "flag"
+	SourceEnv         SourceKind = "env"
+	SourceEnvFile     SourceKind = "env file"
+	SourceFile        SourceKind = "file"
+	SourceDefault     SourceKind = "default"
+	SourceFlagDefault SourceKind = "flag default"
+)
+
+// Source is one of the layers which sets the value of a key
+type Source struct {
+	Kind SourceKind
+	// Name is the file path, env var name or flag name,
+	// empty for defaults and configs read from io.Reader
+	Name string
+	// Line and Column are the position of the key in the file, 0 if unknown
+	Line   int
+	Column int
+	// Value is the raw value set by this layer
+	Value interface{}
+}
+
+func (s *Source) String() string {
+	switch s.Kind {
+	case SourceFlag:
+		return "flag --" + s.Name
+	case SourceFlagDefault:
+		return "flag --" + s.Name + " default"
+	case SourceEnv, SourceEnvFile:
+		return "env " + s.Name
+	case SourceFile:
+		if s.Name == "" {
+			return "config"
+		}
+		if s.Line > 0 {
+			return fmt.Sprintf("file %s:%d:%d", s.Name, s.Line, s.Column)
+		}
+		return "file " + s.Name
+	}
+	return string(s.Kind)
+}
+
+// KeyProvenance tells where the value of a key comes from
+type KeyProvenance struct {
+	Key string
+	// Value is the resolved value of the key
+	Value interface{}
+	// Source is the layer wins, nil if the key is not set
+	Source *Source
+	// Shadowed are the layers overridden by Source, in the order of priority
+	Shadowed []*Source
+	// Secret tells the values are masked in String
+	Secret bool
+}
+
+func (p *KeyProvenance) String() string {
+	if p.Source == nil {
+		return fmt.Sprintf("%s is not set", p.Key)
+	}
+
+	value := func(v interface{}) interface{} {
+		if p.Secret {
+			return maskValue(v)
+		}
+		return v
+	}
+
+	msg := fmt.Sprintf("%s = %v from %s", p.Key, value(p.Value), p.Source)
+	for _, shadowed := range p.Shadowed {
+		msg += fmt.Sprintf(", shadows %v from %s", value(shadowed.Value), shadowed)
+	}
+	return msg
+}
+
+// Provenance wraps the global ConfigReader instance
+func Provenance(key string) *KeyProvenance { return c.Provenance(key) }
+
+// Provenance tells where the value of the key comes from, nil if the key
+// is not a field of the config struct loaded last time
+func (c *ConfigReader) Provenance(key string) *KeyProvenance {
+	structField, ok := c.fields[strings.ToLower(key)]
+	if !ok {
+		return nil
+	}
+	return c.provenance(key, structField)
+}
+
+// Explain wraps the global ConfigReader instance
+func Explain() []*KeyProvenance { return c.Explain() }
+
+// Explain tells where the values of all the keys come from, sorted by keys
+func (c *ConfigReader) Explain() []*KeyProvenance {
+	keys := make([]string, 0, len(c.fields))
+	for key := range c.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	provenances := make([]*KeyProvenance, 0, len(keys))
+	for _, key := range keys {
+		provenances = append(provenances, c.provenance(key, c.fields[key]))
+	}
+	return provenances
+}
+
+// provenance looks up all the layers in the same order as viper does
+func (c *ConfigReader) provenance(fieldKey string, structField reflect.StructField) *KeyProvenance {
+	key := strings.ToLower(fieldKey)
+	tag := structField.Tag
+
+	var sources []*Source
+
+	var flag *pflag.Flag
+	if flagname := tag.Get(tagFlag); flagname != "" && !c.isolated {
+		flag = c.lookupFlag(flagname)
+	}
+	if flag != nil && flag.Changed {
+		sources = append(sources, &Source{Kind: SourceFlag, Name: flag.Name, Value: flag.Value.String()})
+	}
+
+	if !c.isolated {
+		for _, envname := range c.envNames(fieldKey, structField) {
+			if val := os.Getenv(envname); val != "" {
+				sources = append(sources, &Source{Kind: SourceEnv, Name: envname, Value: val})
+			} else if path := os.Getenv(envname + envFileSuffix); path != "" {
+				sources = append(sources, &Source{Kind: SourceEnvFile, Name: envname + envFileSuffix, Value: path})
+			}
+		}
+	}
+
+	// the later merged file overrides the former ones
+	for i := len(c.sources) - 1; i >= 0; i-- {
+		v, err := c.sources[i].viper()
+		if err == nil && v.Get(key) != nil {
+			sources = append(sources, c.sources[i].source(key))
+		}
+	}
+
+	if defval := tag.Get(tagDefault); defval != "" {
+		sources = append(sources, &Source{Kind: SourceDefault, Value: defval})
+	}
+
+	if flag != nil && flag.DefValue != "" {
+		sources = append(sources, &Source{Kind: SourceFlagDefault, Name: flag.Name, Value: flag.DefValue})
+	}
+
+	p := &KeyProvenance{Key: key, Value: c.viper.Get(key), Secret: c.isSecretField(fieldKey, structField)}
+	if len(sources) > 0 {
+		p.Source = sources[0]
+		p.Shadowed = sources[1:]
+	}
+	return p
+}
+
+// envNames returns the env var names of the key that viper looks up in order,
+// the automatic one with the prefix, then the one in the env tag
+func (c *ConfigReader) envNames(fieldKey string, structField reflect.StructField) []string {
+	replacer := strings.NewReplacer(".", "_")
+
+	envnames := []string{replacer.Replace(strings.ToUpper(c.envPrefix + "_" + fieldKey))}
+	if envname := structField.Tag.Get(tagEnv); envname != "" {
+		envnames = append(envnames, replacer.Replace(strings.ToUpper(envname)))
+	}
+	return envnames
+}
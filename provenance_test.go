@@ -0,0 +1,158 @@
+package configreader
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	err := writeFile(fs, "/tmp/config.yaml", []byte(`host: base.local
+port: 80
+db:
+  user: app
+`))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/tmp/config_dev.yaml", []byte(`host: dev.local
+db:
+  user: dev
+`))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/tmp/config_local.json", []byte(`{
+  "db": {
+    "user": "me"
+  }
+}`))
+	assert.Nil(t, err)
+
+	type DBConfig struct {
+		User string
+		Name string `default:"app"`
+	}
+
+	type Conf struct {
+		Host  string   `default:"localhost"`
+		Port  int      `env:"port"`
+		Level string   `flag:"level"`
+		DB    DBConfig `key:"db"`
+		Unset string
+	}
+
+	os.Setenv("APP_PORT", "8080")
+	defer os.Unsetenv("APP_PORT")
+
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flagSet.String("level", "info", "")
+	SetFlagSet(flagSet)
+	AddConfigPath("/tmp")
+
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+
+	p := Provenance("host")
+	assert.Equal(t, "dev.local", p.Value)
+	assert.Equal(t, &Source{Kind: SourceFile, Name: "/tmp/config_dev.yaml", Line: 1, Column: 1, Value: "dev.local"}, p.Source)
+	assert.Len(t, p.Shadowed, 2)
+	assert.Equal(t, "file /tmp/config.yaml:1:1", p.Shadowed[0].String())
+	assert.Equal(t, "default", p.Shadowed[1].String())
+
+	p = Provenance("port")
+	assert.Equal(t, "env APP_PORT", p.Source.String())
+	assert.Equal(t, "port = 8080 from env APP_PORT, shadows 80 from file /tmp/config.yaml:2:1", p.String())
+
+	p = Provenance("db.user")
+	assert.Equal(t, "file /tmp/config_local.json:3:5", p.Source.String())
+	assert.Equal(t, "file /tmp/config_dev.yaml:3:3", p.Shadowed[0].String())
+	assert.Equal(t, "file /tmp/config.yaml:4:3", p.Shadowed[1].String())
+
+	p = Provenance("level")
+	assert.Equal(t, "flag --level default", p.Source.String())
+	assert.Nil(t, flagSet.Set("level", "debug"))
+	p = Provenance("level")
+	assert.Equal(t, "flag --level", p.Source.String())
+	assert.Equal(t, "flag --level default", p.Shadowed[0].String())
+
+	assert.Equal(t, "unset is not set", Provenance("unset").String())
+	assert.Nil(t, Provenance("nokey"))
+
+	keys := []string{}
+	for _, p := range Explain() {
+		keys = append(keys, p.Key)
+	}
+	assert.Equal(t, []string{"db.name", "db.user", "host", "level", "port", "unset"}, keys)
+}
+
+func TestKeyPositions(t *testing.T) {
+	yamlData := []byte(`# comment
+a: 1
+b:
+  c: |
+    d: not a key
+  e:
+    - f: not a key
+  g: 2
+`)
+	assert.Equal(t, map[string]position{
+		"a":   {Line: 2, Column: 1},
+		"b":   {Line: 3, Column: 1},
+		"b.c": {Line: 4, Column: 3},
+		"b.e": {Line: 6, Column: 3},
+		"b.g": {Line: 8, Column: 3},
+	}, keyPositions("yaml", yamlData))
+
+	yamlData = []byte(`base: &base
+  user: app
+  port: 1
+a: {b: 1, c: {d: 2}}
+db:
+  <<: *base
+  port: 2
+  note: >
+    e: not a key
+---
+z: 1
+`)
+	assert.Equal(t, map[string]position{
+		"base":      {Line: 1, Column: 1},
+		"base.user": {Line: 2, Column: 3},
+		"base.port": {Line: 3, Column: 3},
+		"a":         {Line: 4, Column: 1},
+		"a.b":       {Line: 4, Column: 5},
+		"a.c":       {Line: 4, Column: 11},
+		"a.c.d":     {Line: 4, Column: 15},
+		"db":        {Line: 5, Column: 1},
+		"db.user":   {Line: 2, Column: 3},
+		"db.port":   {Line: 7, Column: 3},
+		"db.note":   {Line: 8, Column: 3},
+	}, keyPositions("yaml", yamlData))
+
+	jsonData := []byte(`{"a": 1,
+  "B": {"c": "x,{", "d": [{"e": 1}]}
+}`)
+	assert.Equal(t, map[string]position{
+		"a":   {Line: 1, Column: 2},
+		"b":   {Line: 2, Column: 3},
+		"b.c": {Line: 2, Column: 9},
+		"b.d": {Line: 2, Column: 21},
+	}, keyPositions("json", jsonData))
+
+	tomlData := []byte(`a = 1
+[b]
+c = "x"
+[[d]]
+e = 1
+`)
+	assert.Equal(t, map[string]position{
+		"a":   {Line: 1, Column: 1},
+		"b.c": {Line: 3, Column: 1},
+	}, keyPositions("toml", tomlData))
+}
@@ -0,0 +1,605 @@
+package configreader
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema which the tags could be mapped to
+type jsonSchema struct {
+	Schema      string      `json:"$schema,omitempty"`
+	Type        string      `json:"type,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+
+	Enum             []interface{} `json:"enum,omitempty"`
+	Pattern          string        `json:"pattern,omitempty"`
+	Minimum          json.Number   `json:"minimum,omitempty"`
+	Maximum          json.Number   `json:"maximum,omitempty"`
+	ExclusiveMinimum json.Number   `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum json.Number   `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int64        `json:"minLength,omitempty"`
+	MaxLength        *int64        `json:"maxLength,omitempty"`
+	MinItems         *int64        `json:"minItems,omitempty"`
+	MaxItems         *int64        `json:"maxItems,omitempty"`
+	MinProperties    *int64        `json:"minProperties,omitempty"`
+	MaxProperties    *int64        `json:"maxProperties,omitempty"`
+
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	PropertyNames        *jsonSchema            `json:"propertyNames,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+
+	Extension *schemaExtension `json:"x-configreader,omitempty"`
+}
+
+// schemaExtension keeps the Go type and the tags of the field,
+// so that the struct could be built from the schema, see StructFromJSONSchema
+type schemaExtension struct {
+	Type string `json:"type,omitempty"`
+	Tag  string `json:"tag,omitempty"`
+}
+
+// GenerateJSONSchema generates the JSON Schema (draft 2020-12) of the config struct.
+// The key tags are the property names, the desc (or usage) tags are the descriptions,
+// and the validation rules are mapped to the keywords, except the custom validators.
+// Only `required:"true"` is in the required list, the conditional ones are not.
+func GenerateJSONSchema(confPtr interface{}) ([]byte, error) {
+	err := checkStructPtr(confPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := structSchema(reflect.ValueOf(confPtr).Elem())
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = jsonSchemaDraft
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// structSchema builds the object schema of the struct, the nested keys are nested objects
+func structSchema(structRef reflect.Value) (*jsonSchema, error) {
+	root := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+
+	err := walkThroughStruct("", structRef, func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+		names := strings.Split(fieldKey, ".")
+
+		parent := root
+		for _, name := range names[:len(names)-1] {
+			child, ok := parent.Properties[name]
+			if !ok {
+				child = &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+				parent.Properties[name] = child
+			}
+			parent = child
+		}
+
+		schema, err := fieldSchema(fieldKey, structField)
+		if err != nil {
+			return err
+		}
+
+		name := names[len(names)-1]
+		parent.Properties[name] = schema
+		if structField.Tag.Get(tagRequired) == "true" {
+			parent.Required = append(parent.Required, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+func fieldSchema(fieldKey string, structField reflect.StructField) (*jsonSchema, error) {
+	tag := structField.Tag
+
+	schema, err := typeSchema(structField.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if typeName := goTypeName(structField.Type); typeName != "" || tag != "" {
+		schema.Extension = &schemaExtension{Type: typeName, Tag: string(tag)}
+	}
+
+	schema.Description = tag.Get(tagDesc)
+	if schema.Description == "" {
+		schema.Description = tag.Get(tagUsage)
+	}
+
+	if defval := tag.Get(tagDefault); defval != "" {
+		val, err := flagDefault(structField.Type, defval)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] unable to parse default (%s): %v", fieldKey, defval, err)
+		}
+		schema.Default = val.Interface()
+		if structField.Type == durationType {
+			schema.Default = defval
+		}
+	}
+
+	if validation := tag.Get(tagValidation); validation != "" {
+		expr, err := parseValidation(validation)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %v", fieldKey, err)
+		}
+		applyValidation(schema, structField.Type, expr)
+	}
+
+	return schema, nil
+}
+
+func typeSchema(typ reflect.Type) (*jsonSchema, error) {
+	if typ == durationType {
+		return &jsonSchema{Type: "string"}, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return typeSchema(typ.Elem())
+	case reflect.String:
+		return &jsonSchema{Type: "string"}, nil
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &jsonSchema{Type: "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer", Minimum: "0"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := typeSchema(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return structSchema(reflect.New(typ).Elem())
+	}
+
+	// interface and the others accept any value
+	return &jsonSchema{}, nil
+}
+
+// applyValidation sets the keywords of the validation rules into the schema,
+// the groups are mapped to anyOf unless there is only one
+func applyValidation(schema *jsonSchema, typ reflect.Type, expr validationExpr) {
+	if len(expr) == 1 {
+		for _, rule := range expr[0] {
+			applyRule(schema, typ, rule)
+		}
+		return
+	}
+
+	anyOf := make([]*jsonSchema, 0, len(expr))
+	for _, group := range expr {
+		groupSchema := new(jsonSchema)
+		applied := false
+		for _, rule := range group {
+			if applyRule(groupSchema, typ, rule) {
+				applied = true
+			}
+		}
+
+		// any value may pass the group, so does the whole validation
+		if !applied {
+			return
+		}
+		anyOf = append(anyOf, groupSchema)
+	}
+	schema.AnyOf = anyOf
+}
+
+// applyRule sets the keywords of the rule into the schema, false if it can not be mapped
+func applyRule(schema *jsonSchema, typ reflect.Type, rule *validationRule) bool {
+	kind := typ.Kind()
+	isNumber := typ != durationType && isNumberKind(kind)
+
+	switch rule.action {
+	case actionIn:
+		return applyIn(schema, typ, rule.items)
+	case actionRange:
+		// range of string means the range of the length
+		if kind == reflect.String {
+			schema.MinLength, schema.MaxLength = lengthRange(rule.rng)
+			return true
+		}
+		if !isNumber || !isNumberBound(rule.rng.lVal) || !isNumberBound(rule.rng.rVal) {
+			return false
+		}
+		switch rule.rng.lAct {
+		case ">=":
+			schema.Minimum = json.Number(rule.rng.lVal)
+		case ">":
+			schema.ExclusiveMinimum = json.Number(rule.rng.lVal)
+		}
+		switch rule.rng.rAct {
+		case "<=":
+			schema.Maximum = json.Number(rule.rng.rVal)
+		case "<":
+			schema.ExclusiveMaximum = json.Number(rule.rng.rVal)
+		}
+		return true
+	case actionRegex:
+		if kind != reflect.String {
+			return false
+		}
+		schema.Pattern = rule.regex.String()
+		return true
+	case actionLen:
+		switch kind {
+		case reflect.String:
+			schema.MinLength, schema.MaxLength = lengthRange(rule.rng)
+		case reflect.Slice, reflect.Array:
+			schema.MinItems, schema.MaxItems = lengthRange(rule.rng)
+		case reflect.Map:
+			schema.MinProperties, schema.MaxProperties = lengthRange(rule.rng)
+		default:
+			return false
+		}
+		return true
+	case actionEach:
+		switch kind {
+		case reflect.Slice, reflect.Array:
+			if schema.Items == nil {
+				schema.Items = new(jsonSchema)
+			}
+			return applyRule(schema.Items, typ.Elem(), rule.elem)
+		case reflect.Map:
+			if schema.AdditionalProperties == nil {
+				schema.AdditionalProperties = new(jsonSchema)
+			}
+			return applyRule(schema.AdditionalProperties, typ.Elem(), rule.elem)
+		}
+		return false
+	case actionKeys:
+		if kind != reflect.Map {
+			return false
+		}
+		if schema.PropertyNames == nil {
+			schema.PropertyNames = new(jsonSchema)
+		}
+		return applyRule(schema.PropertyNames, typ.Key(), rule.elem)
+	}
+
+	return false
+}
+
+// applyIn maps the values to enum and the regexes to pattern
+func applyIn(schema *jsonSchema, typ reflect.Type, items []ruleItem) bool {
+	var enum []interface{}
+	var patterns []string
+	for _, item := range items {
+		switch {
+		case item.regex != nil:
+			patterns = append(patterns, item.regex.String())
+		case typ.Kind() == reflect.String:
+			enum = append(enum, item.value)
+		case typ != durationType && isNumberKind(typ.Kind()):
+			if !isNumberBound(item.value) {
+				return false
+			}
+			enum = append(enum, json.Number(item.value))
+		default:
+			return false
+		}
+	}
+
+	if len(patterns) == 0 {
+		schema.Enum = enum
+		return true
+	}
+	if len(enum) == 0 && len(patterns) == 1 {
+		schema.Pattern = patterns[0]
+		return true
+	}
+
+	if len(enum) > 0 {
+		schema.AnyOf = append(schema.AnyOf, &jsonSchema{Enum: enum})
+	}
+	for _, pattern := range patterns {
+		schema.AnyOf = append(schema.AnyOf, &jsonSchema{Pattern: pattern})
+	}
+	return true
+}
+
+// lengthRange converts the range into the inclusive bounds of length
+func lengthRange(rng *rangeRule) (min, max *int64) {
+	if v, err := strconv.ParseInt(rng.lVal, 10, 64); err == nil {
+		if rng.lAct == ">" {
+			v++
+		}
+		if rng.lAct != rangeInf {
+			min = &v
+		}
+	}
+	if v, err := strconv.ParseInt(rng.rVal, 10, 64); err == nil {
+		if rng.rAct == "<" {
+			v--
+		}
+		if rng.rAct != rangeInf {
+			max = &v
+		}
+	}
+	return min, max
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isNumberBound tells whether the bound of the range could be a JSON number, empty means no bound
+func isNumberBound(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// goTypeName returns the name of the type which parseGoType understands,
+// empty for the types which could only be built from the schema, e.g. structs
+func goTypeName(typ reflect.Type) string {
+	if typ == durationType || typ == secretType {
+		return typ.String()
+	}
+
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return typ.Kind().String()
+	case reflect.Interface:
+		return "interface {}"
+	case reflect.Ptr:
+		return goTypeName(typ.Elem())
+	case reflect.Slice, reflect.Array:
+		if elem := goTypeName(typ.Elem()); elem != "" {
+			return "[]" + elem
+		}
+	case reflect.Map:
+		key, elem := goTypeName(typ.Key()), goTypeName(typ.Elem())
+		if key != "" && elem != "" {
+			return "map[" + key + "]" + elem
+		}
+	}
+	return ""
+}
+
+var basicTypes = map[string]reflect.Type{
+	"string":              reflect.TypeOf(""),
+	"bool":                reflect.TypeOf(false),
+	"int":                 reflect.TypeOf(int(0)),
+	"int8":                reflect.TypeOf(int8(0)),
+	"int16":               reflect.TypeOf(int16(0)),
+	"int32":               reflect.TypeOf(int32(0)),
+	"int64":               reflect.TypeOf(int64(0)),
+	"uint":                reflect.TypeOf(uint(0)),
+	"uint8":               reflect.TypeOf(uint8(0)),
+	"uint16":              reflect.TypeOf(uint16(0)),
+	"uint32":              reflect.TypeOf(uint32(0)),
+	"uint64":              reflect.TypeOf(uint64(0)),
+	"float32":             reflect.TypeOf(float32(0)),
+	"float64":             reflect.TypeOf(float64(0)),
+	"time.Duration":       durationType,
+	"configreader.Secret": secretType,
+	"interface {}":        reflect.TypeOf((*interface{})(nil)).Elem(),
+}
+
+// parseGoType parses the type name returned by goTypeName
+func parseGoType(name string) (reflect.Type, error) {
+	if typ, ok := basicTypes[name]; ok {
+		return typ, nil
+	}
+
+	if strings.HasPrefix(name, "[]") {
+		elem, err := parseGoType(name[2:])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+	}
+
+	if strings.HasPrefix(name, "map[") {
+		end := strings.Index(name, "]")
+		if end > 0 {
+			key, err := parseGoType(name[4:end])
+			if err != nil {
+				return nil, err
+			}
+			elem, err := parseGoType(name[end+1:])
+			if err != nil {
+				return nil, err
+			}
+			return reflect.MapOf(key, elem), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported type [%s]", name)
+}
+
+// StructFromJSONSchema builds a config struct from the JSON Schema generated by GenerateJSONSchema,
+// and returns the pointer to a new one. The tags and types of the fields are kept in the x-configreader
+// keyword, hand written schemas without it are accepted as well, with the types, defaults, required lists
+// and descriptions in the schema.
+func StructFromJSONSchema(data []byte) (interface{}, error) {
+	schema := new(jsonSchema)
+	err := json.Unmarshal(data, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema.Type != "object" || schema.Properties == nil {
+		return nil, fmt.Errorf("schema of the config should be an object with properties")
+	}
+
+	typ, err := schemaStruct(schema)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.New(typ).Interface(), nil
+}
+
+func schemaStruct(schema *jsonSchema) (reflect.Type, error) {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]reflect.StructField, 0, len(names))
+	fieldNames := make(map[string]bool)
+	for _, name := range names {
+		prop := schema.Properties[name]
+
+		typ, err := schemaType(prop)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %v", name, err)
+		}
+
+		fieldName := exportedName(name)
+		for i := 2; fieldNames[fieldName]; i++ {
+			fieldName = exportedName(name) + strconv.Itoa(i)
+		}
+		fieldNames[fieldName] = true
+
+		fields = append(fields, reflect.StructField{
+			Name: fieldName,
+			Type: typ,
+			Tag:  schemaTag(name, prop, stringInSlice(name, schema.Required)),
+		})
+	}
+
+	return reflect.StructOf(fields), nil
+}
+
+func schemaType(schema *jsonSchema) (reflect.Type, error) {
+	if schema.Extension != nil && schema.Extension.Type != "" {
+		return parseGoType(schema.Extension.Type)
+	}
+
+	switch schema.Type {
+	case "string":
+		return basicTypes["string"], nil
+	case "boolean":
+		return basicTypes["bool"], nil
+	case "integer":
+		return basicTypes["int64"], nil
+	case "number":
+		return basicTypes["float64"], nil
+	case "array":
+		elem := basicTypes["interface {}"]
+		if schema.Items != nil {
+			var err error
+			elem, err = schemaType(schema.Items)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return reflect.SliceOf(elem), nil
+	case "object":
+		if schema.Properties != nil {
+			return schemaStruct(schema)
+		}
+		elem := basicTypes["interface {}"]
+		if schema.AdditionalProperties != nil {
+			var err error
+			elem, err = schemaType(schema.AdditionalProperties)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return reflect.MapOf(basicTypes["string"], elem), nil
+	}
+
+	return basicTypes["interface {}"], nil
+}
+
+// schemaTag returns the tag kept in the schema, with the tags missing in it filled by the schema
+func schemaTag(name string, schema *jsonSchema, required bool) reflect.StructTag {
+	var tag reflect.StructTag
+	if schema.Extension != nil {
+		tag = reflect.StructTag(schema.Extension.Tag)
+	}
+
+	var tags []string
+	if tag != "" {
+		tags = append(tags, string(tag))
+	}
+	addTag := func(key string, value string) {
+		if _, ok := tag.Lookup(key); !ok && value != "" {
+			tags = append(tags, fmt.Sprintf("%s:%q", key, value))
+		}
+	}
+
+	addTag(tagKey, name)
+	if required {
+		addTag(tagRequired, "true")
+	}
+	addTag(tagDesc, schema.Description)
+	switch v := schema.Default.(type) {
+	case nil:
+	case string:
+		addTag(tagDefault, v)
+	case float64:
+		addTag(tagDefault, strconv.FormatFloat(v, 'f', -1, 64))
+	case []interface{}, map[string]interface{}:
+		if data, err := json.Marshal(v); err == nil {
+			addTag(tagDefault, string(data))
+		}
+	default:
+		addTag(tagDefault, fmt.Sprint(v))
+	}
+
+	return reflect.StructTag(strings.Join(tags, " "))
+}
+
+// exportedName converts the key into an exported Go identifier
+func exportedName(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "F" + name
+	}
+	return name
+}
@@ -0,0 +1,176 @@
+package configreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateJSONSchema(t *testing.T) {
+	type TLSConfig struct {
+		Enabled bool
+		Cert    string `required:"if=tls.enabled"`
+	}
+
+	type Upstream struct {
+		Addr   string `required:"true" validation:"regex:'^[a-z.]+:[0-9]+$'"`
+		Weight uint   `validation:"range:(0, 100]"`
+	}
+
+	type Conf struct {
+		Host      string            `required:"true" desc:"the host to listen" validation:"len:[1, 255]"`
+		Port      int               `default:"80" validation:"range:[1, 65535]"`
+		Level     string            `key:"loglevel" default:"info" validation:"in:[debug, info, r'^warn']"`
+		Mode      string            `validation:"in:[dev, prod]"`
+		Ratio     float64           `validation:"range:[0, 1) | in:[2]"`
+		Timeout   time.Duration     `default:"3s" validation:"range:[1s, 1m]"`
+		Tags      []string          `default:"a,b" validation:"len:(0, 10);each:regex:'^[a-z]+$'"`
+		Labels    map[string]string `validation:"keys:len:[1, 63]"`
+		TLS       TLSConfig         `key:"tls"`
+		Upstreams []Upstream
+		Custom    string `validation:"hostname"`
+	}
+
+	data, err := GenerateJSONSchema(&Conf{})
+	assert.Nil(t, err)
+
+	var generated map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &generated))
+	timeout := generated["properties"].(map[string]interface{})["timeout"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"type": "time.Duration",
+		"tag":  `default:"3s" validation:"range:[1s, 1m]"`,
+	}, timeout["x-configreader"])
+
+	data, err = json.Marshal(withoutExtension(generated))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "host": {"type": "string", "description": "the host to listen", "minLength": 1, "maxLength": 255},
+    "port": {"type": "integer", "default": 80, "minimum": 1, "maximum": 65535},
+    "loglevel": {"type": "string", "default": "info", "anyOf": [{"enum": ["debug", "info"]}, {"pattern": "^warn"}]},
+    "mode": {"type": "string", "enum": ["dev", "prod"]},
+    "ratio": {"type": "number", "anyOf": [{"minimum": 0, "exclusiveMaximum": 1}, {"enum": [2]}]},
+    "timeout": {"type": "string", "default": "3s"},
+    "tags": {
+      "type": "array",
+      "default": ["a", "b"],
+      "minItems": 1,
+      "maxItems": 9,
+      "items": {"type": "string", "pattern": "^[a-z]+$"}
+    },
+    "labels": {
+      "type": "object",
+      "additionalProperties": {"type": "string"},
+      "propertyNames": {"minLength": 1, "maxLength": 63}
+    },
+    "tls": {
+      "type": "object",
+      "properties": {
+        "enabled": {"type": "boolean"},
+        "cert": {"type": "string"}
+      }
+    },
+    "upstreams": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "addr": {"type": "string", "pattern": "^[a-z.]+:[0-9]+$"},
+          "weight": {"type": "integer", "minimum": 0, "exclusiveMinimum": 0, "maximum": 100}
+        },
+        "required": ["addr"]
+      }
+    },
+    "custom": {"type": "string"}
+  },
+  "required": ["host"]
+}`, string(data))
+
+	type Invalid struct {
+		Port int `validation:"range:[1, 2"`
+	}
+	_, err = GenerateJSONSchema(&Invalid{})
+	assert.EqualError(t, err, "[port] invalid validation [range:[1, 2] at position 11: unclosed bracket")
+
+	_, err = GenerateJSONSchema(Invalid{})
+	assert.Equal(t, ErrNotStructPointer, err)
+}
+
+// withoutExtension removes the x-configreader keywords from the schema
+func withoutExtension(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		delete(v, "x-configreader")
+		for key, value := range v {
+			v[key] = withoutExtension(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = withoutExtension(value)
+		}
+	}
+	return v
+}
+
+func TestStructFromJSONSchema(t *testing.T) {
+	defer testTearDown()
+
+	type Upstream struct {
+		Addr string `required:"true"`
+	}
+
+	type Conf struct {
+		Host      string        `key:"host" env:"host" required:"true" desc:"the host"`
+		Timeout   time.Duration `default:"3s"`
+		Labels    map[string]int
+		Upstreams []Upstream
+		TLS       struct {
+			Enabled bool
+			Cert    string `required:"if=tls.enabled"`
+		} `key:"tls"`
+	}
+
+	data, err := GenerateJSONSchema(&Conf{})
+	assert.Nil(t, err)
+
+	confPtr, err := StructFromJSONSchema(data)
+	assert.Nil(t, err)
+
+	rebuilt, err := GenerateJSONSchema(confPtr)
+	assert.Nil(t, err)
+	var want, got interface{}
+	assert.Nil(t, json.Unmarshal(data, &want))
+	assert.Nil(t, json.Unmarshal(rebuilt, &got))
+	assert.Equal(t, withoutExtension(want), withoutExtension(got))
+
+	err = ReadConfig(bytes.NewBufferString(`{"host": "a.local", "labels": {"a": 1}, "tls": {"enabled": true}}`), "json", confPtr)
+	assert.EqualError(t, err, "[tls.cert] is required when [if=tls.enabled]")
+
+	// hand written schemas
+	confPtr, err = StructFromJSONSchema([]byte(`{
+  "type": "object",
+  "properties": {
+    "port": {"type": "integer", "default": 8080},
+    "tags": {"type": "array", "items": {"type": "string"}},
+    "db": {"type": "object", "properties": {"user-name": {"type": "string"}}, "required": ["user-name"]}
+  }
+}`))
+	assert.Nil(t, err)
+	typ := reflect.TypeOf(confPtr).Elem()
+	assert.Equal(t, `key:"db"`, string(typ.Field(0).Tag))
+	assert.Equal(t, `key:"user-name" required:"true"`, string(typ.Field(0).Type.Field(0).Tag))
+	assert.Equal(t, "UserName", typ.Field(0).Type.Field(0).Name)
+	assert.Equal(t, `key:"port" default:"8080"`, string(typ.Field(1).Tag))
+	assert.Equal(t, reflect.TypeOf(int64(0)), typ.Field(1).Type)
+	assert.Equal(t, reflect.TypeOf([]string{}), typ.Field(2).Type)
+
+	_, err = StructFromJSONSchema([]byte(`{"type": "string"}`))
+	assert.EqualError(t, err, "schema of the config should be an object with properties")
+}
@@ -0,0 +1,180 @@
+package configreader
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// SecretMask replaces the values of the secrets in the outputs
+const SecretMask = "******"
+
+const (
+	// envFileSuffix is the suffix of the env var which tells the file to read the value from,
+	// e.g. APP_DB_PASSWORD_FILE=/run/secrets/db
+	envFileSuffix = "_FILE"
+	// fileValuePrefix is the prefix of the value in config files which tells the file to read the value from,
+	// e.g. password: file:/run/secrets/db
+	fileValuePrefix = "file:"
+)
+
+// Secret is a string masked when it's printed, e.g. passwords and tokens,
+// use Value to get the plain text. It's the same as a string field with `secret:"true"`.
+type Secret string
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// String returns the mask, or empty if the secret is empty
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return SecretMask
+}
+
+// GoString masks the secret in %#v as well
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+// Value returns the plain text of the secret
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// isSecret tells if the values of the field must be masked
+func isSecret(structField reflect.StructField) bool {
+	return structField.Type == secretType || structField.Tag.Get(tagSecret) == "true"
+}
+
+// isSecretField tells if the values of the field must be masked, including the ones read from files or decrypted
+func (c *ConfigReader) isSecretField(fieldKey string, structField reflect.StructField) bool {
+	return isSecret(structField) || c.secretKeys[strings.ToLower(fieldKey)]
+}
+
+// isSecretKey tells if the key is of a secret field of the loaded config struct
+func (c *ConfigReader) isSecretKey(key string) bool {
+	structField, ok := c.fields[strings.ToLower(key)]
+	return ok && c.isSecretField(key, structField)
+}
+
+// maskValue masks the value unless it's not set or empty
+func maskValue(value interface{}) interface{} {
+	if value == nil || reflect.ValueOf(value).IsZero() {
+		return value
+	}
+	return SecretMask
+}
+
+// resolveValues reads the values from the files told by the _FILE env vars and the file: values in config files,
+// and decrypts the ENC[...] values in config files, the values are masked as secrets. The failures are reported
+// per key in ValidationErrors. The files are not read if the reader is isolated, as the secrets are usually
+// not mounted where the config files are validated, the keys are unresolved and stand for the zero values,
+// which are neither validated nor passed to the Validate hooks. The encrypted values are still decrypted.
+func (c *ConfigReader) resolveValues() error {
+	// the values resolved by the last load are set in viper, the nil ones fall through to the other layers
+	for key := range c.secretKeys {
+		c.viper.Set(key, nil)
+	}
+	for key := range c.unresolvedKeys {
+		c.viper.Set(key, nil)
+	}
+	c.secretKeys = make(map[string]bool)
+	c.unresolvedKeys = make(map[string]bool)
+
+	keys := make([]string, 0, len(c.fields))
+	for key := range c.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs ValidationErrors
+	for _, key := range keys {
+		source := c.provenance(key, c.fields[key]).Source
+		if path := valueFile(source); path != "" {
+			if c.isolated {
+				// set, but the value is unknown
+				c.viper.Set(key, reflect.Zero(c.fields[key].Type).Interface())
+				c.unresolvedKeys[key] = true
+				continue
+			}
+			data, err := afero.ReadFile(c.fs, path)
+			if err != nil {
+				errs = append(errs, &FieldError{Key: key, Rule: "file", Source: source.String(),
+					Err: fmt.Errorf("[%s] unable to read the value from %s: %v", key, source, err)})
+				continue
+			}
+			c.viper.Set(key, strings.TrimSpace(string(data)))
+			c.secretKeys[key] = true
+		} else if source != nil && source.Kind == SourceFile && isEncrypted(source.Value) {
+			value, err := c.decrypt(source.Value.(string))
+			if err != nil {
+				errs = append(errs, &FieldError{Key: key, Rule: "ENC", Source: source.String(),
+					Err: fmt.Errorf("[%s] unable to decrypt the value from %s: %w", key, source, err)})
+				continue
+			}
+			c.viper.Set(key, value)
+			c.secretKeys[key] = true
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// valueFile returns the path of the file to read the value from, empty if the source is not a reference to a file
+func valueFile(source *Source) string {
+	if source == nil {
+		return ""
+	}
+
+	switch source.Kind {
+	case SourceEnvFile:
+		return fmt.Sprint(source.Value)
+	case SourceFile:
+		if value, ok := source.Value.(string); ok && strings.HasPrefix(value, fileValuePrefix) {
+			return strings.TrimPrefix(value, fileValuePrefix)
+		}
+	}
+	return ""
+}
+
+// hasUnresolvedKeys tells if any of the keys in the struct of the key is unresolved, the root struct if it's empty
+func (c *ConfigReader) hasUnresolvedKeys(structKey string) bool {
+	prefix := strings.ToLower(structKey) + "."
+	for key := range c.unresolvedKeys {
+		if structKey == "" || strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskResolvedValues masks the settings of the keys whose values are read from files or decrypted
+func (c *ConfigReader) maskResolvedValues(settings map[string]interface{}) {
+	for key := range c.secretKeys {
+		names := strings.Split(key, ".")
+		parent := settings
+		for _, name := range names[:len(names)-1] {
+			child, ok := parent[name].(map[string]interface{})
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = child
+		}
+		if parent == nil {
+			continue
+		}
+
+		name := names[len(names)-1]
+		if value, ok := parent[name]; ok {
+			parent[name] = maskValue(value)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package configreader
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+)
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	assert.Nil(t, w.Close())
+	data, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestSecret(t *testing.T) {
+	s := Secret("p@ss")
+	assert.Equal(t, "******", s.String())
+	assert.Equal(t, "****** ******", fmt.Sprintf("%v %s", s, s))
+	assert.Equal(t, `"******"`, fmt.Sprintf("%#v", s))
+	assert.Equal(t, "p@ss", s.Value())
+	assert.Equal(t, "", Secret("").String())
+}
+
+func TestSecretMasked(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	err := writeFile(fs, "/tmp/config.yaml", []byte(`user: app
+password: p@ss
+token: t0ken
+`))
+	assert.Nil(t, err)
+
+	type ValidatedConf struct {
+		Token string `key:"token" secret:"true" validation:"len:[8,]"`
+	}
+
+	type Conf struct {
+		User     string `key:"user"`
+		Password Secret `key:"password"`
+		Token    string `key:"token" secret:"true"`
+	}
+
+	AddConfigPath("/tmp")
+	err = LoadConfig(&ValidatedConf{})
+	assert.EqualError(t, err, "[token] did not pass validation [len:[8,]]. real [******]")
+	var fieldErr *FieldError
+	assert.True(t, xerrors.As(err, &fieldErr))
+	assert.Equal(t, SecretMask, fieldErr.Value)
+
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "p@ss", conf.Password.Value())
+	assert.Equal(t, "t0ken", conf.Token)
+
+	out := captureStdout(t, func() { PrintConfig(&conf) })
+	assert.Equal(t, "user: app\npassword: ******\ntoken: ******\n", out)
+	out = captureStdout(t, func() { PrintConfig(&conf, WithUnmaskedSecrets()) })
+	assert.Equal(t, "user: app\npassword: p@ss\ntoken: t0ken\n", out)
+
+	out = captureStdout(t, func() { Debug() })
+	assert.Equal(t, "password: ******\ntoken: ******\nuser: app\n", out)
+	out = captureStdout(t, func() { Debug(WithUnmaskedSecrets()) })
+	assert.Contains(t, out, "p@ss")
+
+	p := Provenance("password")
+	assert.Equal(t, "password = ****** from file /tmp/config.yaml:2:1", p.String())
+	assert.Equal(t, "p@ss", p.Value)
+
+	buf := new(bytes.Buffer)
+	err = DumpConfigTo(buf, "yaml", &conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "password: '******'\ntoken: '******'\nuser: app\n", buf.String())
+
+	buf.Reset()
+	err = DumpConfigTo(buf, "yaml", &conf, WithUnmaskedSecrets())
+	assert.Nil(t, err)
+	assert.Equal(t, "password: p@ss\ntoken: t0ken\nuser: app\n", buf.String())
+
+	buf.Reset()
+	err = DumpEffective(buf, "yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "password: '******'\ntoken: '******'\nuser: app\n", buf.String())
+
+	err = DumpConfig("/tmp/dump.json", &conf)
+	assert.Nil(t, err)
+	data, err := afero.ReadFile(fs, "/tmp/dump.json")
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "p@ss")
+}
+
+func TestSecretFiles(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	err := writeFile(fs, "/tmp/config.yaml", []byte(`user: app
+password: file:/run/secrets/db
+token: plain
+`))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/run/secrets/db", []byte("p@ss\n"))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/run/secrets/token", []byte(" t0ken \n"))
+	assert.Nil(t, err)
+
+	type Conf struct {
+		User     string `key:"user"`
+		Password string `key:"password"`
+		Token    string `key:"token"`
+	}
+
+	os.Setenv("APP_TOKEN_FILE", "/run/secrets/token")
+	defer os.Unsetenv("APP_TOKEN_FILE")
+
+	AddConfigPath("/tmp")
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+	assert.Equal(t, Conf{User: "app", Password: "p@ss", Token: "t0ken"}, conf)
+
+	p := Provenance("token")
+	assert.Equal(t, &Source{Kind: SourceEnvFile, Name: "APP_TOKEN_FILE", Value: "/run/secrets/token"}, p.Source)
+	assert.Equal(t, "token = ****** from env APP_TOKEN_FILE, shadows ****** from file /tmp/config.yaml:3:1", p.String())
+	assert.Equal(t, "password = ****** from file /tmp/config.yaml:2:1", Provenance("password").String())
+
+	out := captureStdout(t, func() { PrintConfig(&conf) })
+	assert.Equal(t, "user: app\npassword: ******\ntoken: ******\n", out)
+
+	buf := new(bytes.Buffer)
+	err = DumpConfigTo(buf, "yaml", &conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "password: '******'\ntoken: '******'\nuser: app\n", buf.String())
+
+	// the env var wins over the one of the file
+	os.Setenv("APP_TOKEN", "env")
+	defer os.Unsetenv("APP_TOKEN")
+
+	Reset()
+	SetFs(fs)
+	AddConfigPath("/tmp")
+	conf = Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "env", conf.Token)
+	assert.Equal(t, "token = env from env APP_TOKEN, shadows plain from file /tmp/config.yaml:3:1", Provenance("token").String())
+
+	err = writeFile(fs, "/tmp/config.yaml", []byte("password: file:/run/secrets/missing\n"))
+	assert.Nil(t, err)
+
+	Reset()
+	SetFs(fs)
+	AddConfigPath("/tmp")
+	err = LoadConfig(&Conf{})
+	assert.EqualError(t, err, "[password] unable to read the value from file /tmp/config.yaml:1:1: open /run/secrets/missing: file does not exist")
+
+	// the resolved values are not kept when loading again with the same reader
+	os.Unsetenv("APP_TOKEN")
+	err = writeFile(fs, "/tmp/config.yaml", []byte("password: file:/run/secrets/db\ntoken: plain\n"))
+	assert.Nil(t, err)
+
+	Reset()
+	SetFs(fs)
+	AddConfigPath("/tmp")
+	conf = Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+	assert.Equal(t, Conf{Password: "p@ss", Token: "t0ken"}, conf)
+
+	err = writeFile(fs, "/tmp/config.yaml", []byte("password: plain\ntoken: plain\n"))
+	assert.Nil(t, err)
+	os.Setenv("APP_TOKEN", "env")
+
+	conf = Conf{}
+	err = LoadConfig(&conf)
+	assert.Nil(t, err)
+	assert.Equal(t, Conf{Password: "plain", Token: "env"}, conf)
+	assert.Equal(t, "password = plain from file /tmp/config.yaml:1:1", Provenance("password").String())
+	assert.Equal(t, "token = env from env APP_TOKEN, shadows plain from file /tmp/config.yaml:2:1", Provenance("token").String())
+}
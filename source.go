@@ -0,0 +1,76 @@
+package configreader
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// configSource is one of the config files merged into the viper
+type configSource struct {
+	// name is the path of the file, empty if it's read from io.Reader
+	name       string
+	configType string
+	data       []byte
+
+	// parsed lazily
+	settings  *viper.Viper
+	positions map[string]position
+}
+
+func (c *ConfigReader) addConfigSource(filename string) error {
+	data, err := afero.ReadFile(c.fs, filename)
+	if err != nil {
+		return err
+	}
+
+	c.sources = append(c.sources, &configSource{
+		name:       filename,
+		configType: strings.TrimPrefix(filepath.Ext(filename), "."),
+		data:       data,
+	})
+	return nil
+}
+
+// viper returns a viper which only holds the settings of the source
+func (s *configSource) viper() (*viper.Viper, error) {
+	if s.settings == nil {
+		v := viper.New()
+		v.SetConfigType(s.configType)
+		if err := v.ReadConfig(bytes.NewReader(s.data)); err != nil {
+			return nil, err
+		}
+		s.settings = v
+	}
+	return s.settings, nil
+}
+
+// keys returns all the keys defined in the source alone, sorted
+func (s *configSource) keys() ([]string, error) {
+	v, err := s.viper()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := v.AllKeys()
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// source returns the Source of the key in this config file
+func (s *configSource) source(key string) *Source {
+	if s.positions == nil {
+		s.positions = keyPositions(s.configType, s.data)
+	}
+
+	pos := s.positions[key]
+	source := &Source{Kind: SourceFile, Name: s.name, Line: pos.Line, Column: pos.Column}
+	if v, err := s.viper(); err == nil {
+		source.Value = v.Get(key)
+	}
+	return source
+}
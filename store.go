@@ -0,0 +1,141 @@
+package configreader
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Change is delivered to the subscribers of Store when the config is reloaded
+type Change struct {
+	// Old and New are the snapshots before and after the reload
+	Old interface{}
+	New interface{}
+	// Keys are the full keys of the changed fields, sorted
+	Keys []string
+}
+
+// LoadStore wraps the global ConfigReader instance
+func LoadStore(confPtr interface{}) (*Store, error) { return c.LoadStore(confPtr) }
+
+// LoadStore loads configs into confPtr and returns a Store holding a snapshot of it, which shares
+// no maps, slices or pointers with confPtr. The config files are watched and the Store is updated
+// once they change, see Watch
+func (c *ConfigReader) LoadStore(confPtr interface{}) (*Store, error) {
+	s := new(Store)
+
+	// the updates wait for the initial snapshot
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, err := c.Watch(confPtr, s.update)
+	if err != nil {
+		return nil, err
+	}
+	s.watcher = w
+	s.value.Store(deepCopy(reflect.ValueOf(confPtr).Elem()).Interface())
+
+	return s, nil
+}
+
+// Store holds the snapshot of the config, it's safe for concurrent use
+type Store struct {
+	value   atomic.Value
+	watcher *Watcher
+
+	mu          sync.Mutex
+	subscribers []chan Change
+	stopped     bool
+}
+
+// Get returns the snapshot of the config struct, the struct value not the pointer.
+// The snapshot is a copy of the loaded struct, but it's shared by all the callers of Get,
+// the maps and slices in it must not be modified.
+func (s *Store) Get() interface{} {
+	return s.value.Load()
+}
+
+// Subscribe returns a channel delivering the changes of the config.
+// A change not received yet is merged with the next one, so a slow subscriber
+// never blocks the others and always gets the latest snapshot.
+// The channel is closed when the Store is stopped.
+func (s *Store) Subscribe() <-chan Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan Change, 1)
+	if s.stopped {
+		close(ch)
+		return ch
+	}
+	s.subscribers = append(s.subscribers, ch)
+	return ch
+}
+
+// Stop stops watching the config files and closes the subscribed channels
+func (s *Store) Stop() {
+	s.watcher.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	for _, ch := range s.subscribers {
+		close(ch)
+	}
+	s.subscribers = nil
+}
+
+func (s *Store) update(oldPtr, newPtr interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.value.Load()
+	next := deepCopy(reflect.ValueOf(newPtr).Elem()).Interface()
+	s.value.Store(next)
+
+	change := Change{Old: old, New: next, Keys: changedKeys(old, next)}
+	for _, ch := range s.subscribers {
+		select {
+		case ch <- change:
+		default:
+			select {
+			case pending := <-ch:
+				ch <- Change{Old: pending.Old, New: next, Keys: changedKeys(pending.Old, next)}
+			default:
+				ch <- change
+			}
+		}
+	}
+}
+
+// ChangedKeys returns the full keys of the fields which differ in two config structs
+// of the same type, either the structs or the pointers to them
+func ChangedKeys(old, new interface{}) []string {
+	return changedKeys(reflect.Indirect(reflect.ValueOf(old)).Interface(), reflect.Indirect(reflect.ValueOf(new)).Interface())
+}
+
+// changedKeys compares the fields of two config structs of the same type,
+// the keys are the same as the ones walkThroughStruct passes
+func changedKeys(old, new interface{}) []string {
+	oldValues := make(map[string]interface{})
+	_ = walkThroughStruct("", reflect.ValueOf(old), func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+		oldValues[fieldKey] = structRef.Interface()
+		return nil
+	})
+
+	var keys []string
+	_ = walkThroughStruct("", reflect.ValueOf(new), func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+		if !reflect.DeepEqual(oldValues[fieldKey], structRef.Interface()) {
+			keys = append(keys, fieldKey)
+		}
+		return nil
+	})
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package configreader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	err := writeFile(fs, "/tmp/config.yaml", []byte(`host: base.local
+db:
+  user: app
+  tags: [a]
+`))
+	assert.Nil(t, err)
+
+	type DBConfig struct {
+		User string
+		Tags []string
+	}
+
+	type Conf struct {
+		Host string
+		Port int `default:"80"`
+		DB   DBConfig
+	}
+
+	AddConfigPath("/tmp")
+	SetWatchInterval(10 * time.Millisecond)
+
+	conf := Conf{}
+	s, err := LoadStore(&conf)
+	assert.Nil(t, err)
+	defer s.Stop()
+
+	old := s.Get().(Conf)
+	assert.Equal(t, "base.local", old.Host)
+
+	// the snapshot shares nothing with the loaded struct
+	conf.DB.Tags[0] = "changed"
+	assert.Equal(t, []string{"a"}, s.Get().(Conf).DB.Tags)
+
+	changes := s.Subscribe()
+
+	err = writeFile(fs, "/tmp/config.yaml", []byte(`host: base.local
+db:
+  user: root
+  tags: [a, b]
+`))
+	assert.Nil(t, err)
+	select {
+	case change := <-changes:
+		assert.Equal(t, []string{"db.tags", "db.user"}, change.Keys)
+		assert.Equal(t, old, change.Old)
+		assert.Equal(t, Conf{Host: "base.local", Port: 80, DB: DBConfig{User: "root", Tags: []string{"a", "b"}}}, change.New)
+	case <-time.After(time.Second):
+		t.Fatal("config is not reloaded")
+	}
+	assert.Equal(t, "root", s.Get().(Conf).DB.User)
+
+	s.Stop()
+	_, ok := <-changes
+	assert.False(t, ok)
+}
+
+func TestChangedKeys(t *testing.T) {
+	type Embedded struct {
+		Level string
+	}
+
+	type Conf struct {
+		Embedded
+		Host   string `key:"addr"`
+		Labels map[string]string
+		Skip   string `key:"-"`
+	}
+
+	old := Conf{Embedded: Embedded{Level: "info"}, Host: "a", Labels: map[string]string{"a": "b"}}
+	assert.Empty(t, changedKeys(old, old))
+
+	next := Conf{Embedded: Embedded{Level: "debug"}, Host: "b", Labels: map[string]string{"a": "b"}, Skip: "x"}
+	assert.Equal(t, []string{"addr", "level"}, changedKeys(old, next))
+}
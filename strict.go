@@ -0,0 +1,128 @@
+package configreader
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// checkUnknownKeys reports all the keys in config sources which have no matching field
+func (c *ConfigReader) checkUnknownKeys() (ValidationErrors, error) {
+	fieldKeys := make([]string, 0, len(c.fields))
+	for fieldKey := range c.fields {
+		fieldKeys = append(fieldKeys, fieldKey)
+	}
+	sort.Strings(fieldKeys)
+
+	var errs ValidationErrors
+	for _, source := range c.sources {
+		keys, err := source.keys()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			if isKnownKey(key, c.fields) {
+				continue
+			}
+
+			keySource := source.source(key)
+			errs = append(errs, &FieldError{
+				Key:    key,
+				Rule:   "strict",
+				Value:  keySource.Value,
+				Source: keySource.String(),
+				Err: &UnknownKeyError{
+					Key:        key,
+					File:       keySource.Name,
+					Line:       keySource.Line,
+					Column:     keySource.Column,
+					Suggestion: suggestKey(key, fieldKeys),
+				},
+			})
+		}
+	}
+
+	return errs, nil
+}
+
+// isKnownKey tells if the key is a field, or under a map or interface{} field, or a key of the struct
+// a pointer field points to, or a parent of fields
+func isKnownKey(key string, fields map[string]reflect.StructField) bool {
+	for fieldKey, structField := range fields {
+		if key == fieldKey || strings.HasPrefix(fieldKey, key+".") {
+			return true
+		}
+		if strings.HasPrefix(key, fieldKey+".") && hasChildKey(structField.Type, strings.TrimPrefix(key, fieldKey+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasChildKey tells if the field of the type holds the child key, any key under a map or interface{},
+// or the keys of the struct the pointer points to, as they are not walked through like the struct fields
+func hasChildKey(typ reflect.Type, childKey string) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Map, reflect.Interface:
+		return true
+	case reflect.Struct:
+		fields := make(map[string]reflect.StructField)
+		_ = walkThroughStruct("", reflect.New(typ).Elem(), func(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+			fields[strings.ToLower(fieldKey)] = structField
+			return nil
+		})
+		return isKnownKey(childKey, fields)
+	}
+	return false
+}
+
+// suggestKey returns the most similar field key, or empty if none of them is similar enough
+func suggestKey(key string, fieldKeys []string) string {
+	suggestion := ""
+	minDistance := len(key)/3 + 2
+	for _, fieldKey := range fieldKeys {
+		if distance := editDistance(key, fieldKey); distance < minDistance {
+			suggestion = fieldKey
+			minDistance = distance
+		}
+	}
+	return suggestion
+}
+
+// editDistance is the levenshtein distance of the two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,97 @@
+package configreader
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrict(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	err := writeFile(fs, "/tmp/config.yaml", []byte(`
+logleve: info
+tls:
+  cert: /etc/tls.crt
+  enabled: true
+labels:
+  team: infra
+`))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/tmp/config_dev.yaml", []byte(`
+tls:
+  key: /etc/tls.key
+zzz: 1
+`))
+	assert.Nil(t, err)
+
+	type TLSConfig struct {
+		Enabled bool
+		Cert    string
+	}
+
+	type Conf struct {
+		LogLevel string    `default:"info"`
+		TLS      TLSConfig `key:"tls"`
+		Labels   map[string]string
+	}
+
+	SetStrict(true)
+	AddConfigPath("/tmp")
+
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.NotNil(t, err)
+
+	var verrs ValidationErrors
+	assert.ErrorAs(t, err, &verrs)
+	assert.Len(t, verrs, 3)
+	assert.Equal(t, "unknown config key [logleve] in /tmp/config.yaml:2:1, did you mean [loglevel]?", verrs[0].Error())
+	assert.Equal(t, "file /tmp/config.yaml:2:1", verrs[0].Source)
+	assert.Equal(t, "unknown config key [tls.key] in /tmp/config_dev.yaml:3:3, did you mean [tls.cert]?", verrs[1].Error())
+	assert.Equal(t, "unknown config key [zzz] in /tmp/config_dev.yaml:4:1", verrs[2].Error())
+
+	var unknownErr *UnknownKeyError
+	assert.ErrorAs(t, err, &unknownErr)
+	assert.Equal(t, "logleve", unknownErr.Key)
+	assert.Equal(t, "loglevel", unknownErr.Suggestion)
+
+	Reset()
+	SetStrict(true)
+	conf = Conf{}
+	err = ReadConfig(bytes.NewBufferString(`{"loglevel": "warn", "labels": {"a": "b"}}`), "json", &conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "warn", conf.LogLevel)
+
+	// the child keys are unknown under the scalar fields
+	Reset()
+	SetStrict(true)
+	conf = Conf{}
+	err = ReadConfig(bytes.NewBufferString(`{"loglevel": {"extra": "x"}, "tls": {"cert": {"path": "/etc/tls.crt"}}}`), "json", &conf)
+	assert.ErrorAs(t, err, &verrs)
+	assert.Len(t, verrs, 2)
+	assert.Equal(t, "loglevel.extra", verrs[0].Key)
+	assert.Equal(t, "tls.cert.path", verrs[1].Key)
+
+	// the keys of the struct a pointer field points to are known
+	type PtrConf struct {
+		TLS *TLSConfig `key:"tls"`
+	}
+
+	Reset()
+	SetStrict(true)
+	ptrConf := PtrConf{}
+	err = ReadConfig(bytes.NewBufferString(`{"tls": {"cert": "/etc/tls.crt", "key": "/etc/tls.key"}}`), "json", &ptrConf)
+	assert.ErrorAs(t, err, &verrs)
+	assert.Len(t, verrs, 1)
+	assert.Equal(t, "unknown config key [tls.key]", verrs[0].Error())
+
+	err = ReadConfig(bytes.NewBufferString(`{"tls": {"cert": "/etc/tls.crt", "enabled": true}}`), "json", &ptrConf)
+	assert.Nil(t, err)
+	assert.Equal(t, &TLSConfig{Enabled: true, Cert: "/etc/tls.crt"}, ptrConf.TLS)
+}
@@ -0,0 +1,100 @@
+package configreader
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// Issue is one of the problems of the config files found by ValidateFile
+type Issue struct {
+	// File is the config file where the problem is, the base file if it's not in a file,
+	// e.g. a required key is missing
+	File string
+	// Line and Column are the position of the key in the file, 0 if unknown
+	Line   int
+	Column int
+	// Key is the key of the problem, empty if it's not about one key
+	Key     string
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Column, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.File, i.Message)
+}
+
+// ValidateFile wraps the global ConfigReader instance
+func ValidateFile(path string, confPtr interface{}) []Issue { return c.ValidateFile(path, confPtr) }
+
+// ValidateFile merges the config file at path with its overlays as LoadFromFile does,
+// and reports all the problems of them against the config struct, including the unknown keys.
+// Neither env vars nor flags are bound, so the result only depends on the files and the defaults,
+// use SetConfigEnv to choose the overlay. confPtr only tells the type, it is never populated.
+func (c *ConfigReader) ValidateFile(path string, confPtr interface{}) []Issue {
+	err := checkStructPtr(confPtr)
+	if err != nil {
+		return []Issue{{File: path, Message: err.Error()}}
+	}
+
+	r := c.isolate()
+	r.SetConfigPaths([]string{filepath.Dir(path)})
+	r.SetConfigName(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+
+	err = r.loadConfig(reflect.New(reflect.TypeOf(confPtr).Elem()).Interface())
+	if err == nil {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if !xerrors.As(err, &errs) {
+		return []Issue{{File: path, Message: err.Error()}}
+	}
+
+	issues := make([]Issue, 0, len(errs))
+	for _, fieldErr := range errs {
+		issues = append(issues, r.issue(path, fieldErr))
+	}
+	return issues
+}
+
+// isolate creates a reader with the same settings, which binds neither env vars nor flags
+func (c *ConfigReader) isolate() *ConfigReader {
+	r := c.clone()
+	r.viper = viper.New()
+	r.viper.SetFs(r.fs)
+	r.flagset = nil
+	r.ownFlagSet = false
+	r.autoParseFlags = false
+	r.strict = true
+	r.isolated = true
+	return r
+}
+
+// issue locates the error in the config files
+func (c *ConfigReader) issue(path string, fieldErr *FieldError) Issue {
+	issue := Issue{File: path, Key: fieldErr.Key, Message: fieldErr.Error()}
+
+	var unknownErr *UnknownKeyError
+	if xerrors.As(fieldErr, &unknownErr) {
+		// the position is told by the issue already
+		issue.File, issue.Line, issue.Column = unknownErr.File, unknownErr.Line, unknownErr.Column
+		issue.Message = unknownErr.message("")
+		return issue
+	}
+
+	structField, ok := c.fields[strings.ToLower(fieldErr.Key)]
+	if !ok {
+		return issue
+	}
+	if source := c.provenance(fieldErr.Key, structField).Source; source != nil && source.Kind == SourceFile {
+		issue.File, issue.Line, issue.Column = source.Name, source.Line, source.Column
+	}
+	return issue
+}
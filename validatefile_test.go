@@ -0,0 +1,92 @@
+package configreader
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFile(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	err := writeFile(fs, "/etc/app/config.yaml", []byte(`host: base.local
+port: 80
+`))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/etc/app/config_prod.yaml", []byte(`port: 0
+db:
+  usr: app
+`))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/etc/app/config_staging.yaml", []byte(`host: staging.local`))
+	assert.Nil(t, err)
+
+	type DBConfig struct {
+		User string `required:"true"`
+	}
+
+	type Conf struct {
+		Host string   `required:"true"`
+		Port int      `flag:"port" validation:"range:[1,65535]"`
+		DB   DBConfig `key:"db"`
+	}
+
+	// neither env vars nor flags hide the problems of the files
+	os.Setenv("APP_PORT", "8080")
+	defer os.Unsetenv("APP_PORT")
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flagSet.String("port", "8081", "")
+	SetFlagSet(flagSet)
+
+	SetConfigEnv("prod")
+	conf := Conf{}
+	issues := ValidateFile("/etc/app/config.yaml", &conf)
+	assert.Equal(t, []Issue{
+		{
+			File: "/etc/app/config_prod.yaml", Line: 3, Column: 3, Key: "db.usr",
+			Message: "unknown config key [db.usr], did you mean [db.user]?",
+		},
+		{
+			File: "/etc/app/config_prod.yaml", Line: 1, Column: 1, Key: "port",
+			Message: "[port] did not pass validation [range:[1,65535]]. real [0]",
+		},
+		{
+			File: "/etc/app/config.yaml", Key: "db.user",
+			Message: "[db.user] is required",
+		},
+	}, issues)
+	assert.Equal(t, "/etc/app/config_prod.yaml:1:1: [port] did not pass validation [range:[1,65535]]. real [0]", issues[1].String())
+	assert.Equal(t, "/etc/app/config.yaml: [db.user] is required", issues[2].String())
+	assert.Equal(t, Conf{}, conf)
+
+	SetConfigEnv("staging")
+	issues = ValidateFile("/etc/app/config.yaml", &conf)
+	assert.Len(t, issues, 1)
+	assert.Equal(t, "db.user", issues[0].Key)
+
+	issues = ValidateFile("/etc/app/missing.yaml", &conf)
+	assert.Len(t, issues, 1)
+	assert.Equal(t, "/etc/app/missing.yaml", issues[0].File)
+
+	// the values in the files which are not read are neither validated nor passed to the hooks
+	err = writeFile(fs, "/etc/svc/config.yaml", []byte(`min: file:/run/secrets/min
+max: 5
+tls:
+  cert: /etc/cert.pem
+  key: file:/run/secrets/key
+peer:
+  cert: /etc/peer.pem
+`))
+	assert.Nil(t, err)
+
+	SetConfigEnv("")
+	issues = ValidateFile("/etc/svc/config.yaml", &hookConfig{})
+	assert.Equal(t, []Issue{{File: "/etc/svc/config.yaml", Key: "peer",
+		Message: "[peer] is invalid: cert and key must be set together"}}, issues)
+}
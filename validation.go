@@ -0,0 +1,559 @@
+package configreader
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// The grammar of the validation tag
+//
+//	validation = group { "|" group }   passes if any of the groups passes
+//	group      = rule { ";" rule }     passes if all of the rules pass
+//	rule       = action [ ":" args ]
+//
+// Separators inside brackets or single quotes belong to the args,
+// e.g. `validation:"range:[1, 64];regex:'^[a-z]+$'"`
+const (
+	actionIn    = "in"
+	actionRange = "range"
+	actionRegex = "regex"
+	actionLen   = "len"
+	actionEach  = "each"
+	actionKeys  = "keys"
+
+	rangeInf = "inf"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+
+	// errNotPassed is the failure of the builtin actions
+	errNotPassed = xerrors.New("not passed")
+)
+
+// validationExpr holds the groups of a validation tag,
+// rules in one group are ANDed and the groups are ORed
+type validationExpr [][]*validationRule
+
+// validationRule is one rule of the validation tag, e.g. 'range:[1, 64]'
+type validationRule struct {
+	raw    string
+	action string
+	args   string
+
+	items []ruleItem      // args of in
+	rng   *rangeRule      // args of range and len
+	regex *regexp.Regexp  // args of regex
+	elem  *validationRule // args of each and keys
+}
+
+// ruleItem is one item of the in action, either a value or a regex like r'^a.*'
+type ruleItem struct {
+	value string
+	regex *regexp.Regexp
+}
+
+// rangeRule is the args of the range action, e.g. '[1, 64)' or '(, 10]'
+type rangeRule struct {
+	lAct, rAct string
+	lVal, rVal string
+}
+
+////////// Parse rules
+
+func parseValidation(validation string) (validationExpr, error) {
+	var expr validationExpr
+	var group []*validationRule
+
+	depth := 0
+	quoted := false
+	start := 0
+	for i := 0; i <= len(validation); i++ {
+		end := i == len(validation)
+
+		var ch byte
+		if !end {
+			ch = validation[i]
+		}
+
+		switch {
+		case end || (!quoted && depth == 0 && (ch == ';' || ch == '|')):
+			if quoted {
+				return nil, validationSyntaxError(validation, i, "unterminated quote")
+			}
+			if depth > 0 {
+				return nil, validationSyntaxError(validation, i, "unclosed bracket")
+			}
+
+			rule, err := parseValidationRule(strings.TrimSpace(validation[start:i]))
+			if err != nil {
+				return nil, validationSyntaxError(validation, start, err.Error())
+			}
+
+			group = append(group, rule)
+			if end || ch == '|' {
+				expr = append(expr, group)
+				group = nil
+			}
+			start = i + 1
+		case ch == '\'':
+			quoted = !quoted
+		case quoted:
+		case ch == '[' || ch == '(':
+			depth++
+		case ch == ']' || ch == ')':
+			depth--
+			if depth < 0 {
+				return nil, validationSyntaxError(validation, i, "unexpected %q", ch)
+			}
+		}
+	}
+
+	return expr, nil
+}
+
+func validationSyntaxError(validation string, pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("invalid validation [%s] at position %d: %s", validation, pos, fmt.Sprintf(format, args...))
+}
+
+func parseValidationRule(raw string) (*validationRule, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("empty rule")
+	}
+
+	rule := &validationRule{raw: raw, action: raw}
+	if idx := strings.Index(raw, ":"); idx >= 0 {
+		rule.action = strings.TrimSpace(raw[:idx])
+		rule.args = strings.TrimSpace(raw[idx+1:])
+	}
+	if rule.action == "" {
+		return nil, fmt.Errorf("missing action in rule [%s]", raw)
+	}
+
+	var err error
+	switch rule.action {
+	case actionIn:
+		rule.items, err = parseInItems(rule.args)
+	case actionRange, actionLen:
+		rule.rng, err = parseRangeRule(rule.args)
+	case actionRegex:
+		rule.regex, err = parseRegex(rule.args)
+	case actionEach, actionKeys:
+		rule.elem, err = parseValidationRule(rule.args)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rule [%s]: %v", raw, err)
+	}
+
+	return rule, nil
+}
+
+func parseInItems(args string) ([]ruleItem, error) {
+	last := len(args) - 1
+	if last < 1 || args[0] != '[' || args[last] != ']' {
+		return nil, fmt.Errorf("want a list like [a, b]")
+	}
+
+	var items []ruleItem
+	for _, s := range splitArgs(args[1:last], ',') {
+		if s == "" {
+			return nil, fmt.Errorf("empty item")
+		}
+
+		value, isRegex := unquote(s)
+		if !isRegex {
+			items = append(items, ruleItem{value: value})
+			continue
+		}
+
+		r, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, ruleItem{value: value, regex: r})
+	}
+
+	return items, nil
+}
+
+func parseRangeRule(args string) (*rangeRule, error) {
+	invalidRule := fmt.Errorf("want a range like [1, 10) or (, 10]")
+
+	last := len(args) - 1
+	if last < 1 {
+		return nil, invalidRule
+	}
+
+	rng := new(rangeRule)
+	switch args[0] {
+	case '[':
+		rng.lAct = ">="
+	case '(':
+		rng.lAct = ">"
+	default:
+		return nil, invalidRule
+	}
+
+	switch args[last] {
+	case ']':
+		rng.rAct = "<="
+	case ')':
+		rng.rAct = "<"
+	default:
+		return nil, invalidRule
+	}
+
+	// both [1, 10] and [1:10] are accepted
+	bounds := splitArgs(args[1:last], ',')
+	if len(bounds) == 1 {
+		bounds = splitArgs(args[1:last], ':')
+	}
+	if len(bounds) != 2 {
+		return nil, invalidRule
+	}
+
+	rng.lVal = bounds[0]
+	rng.rVal = bounds[1]
+
+	if len(rng.lVal) == 0 {
+		rng.lAct = rangeInf
+	}
+	if len(rng.rVal) == 0 {
+		rng.rAct = rangeInf
+	}
+	if rng.lAct == rangeInf && rng.rAct == rangeInf {
+		return nil, invalidRule
+	}
+
+	return rng, nil
+}
+
+func parseRegex(args string) (*regexp.Regexp, error) {
+	expr, _ := unquote(args)
+	if expr == "" {
+		return nil, fmt.Errorf("empty regex")
+	}
+	return regexp.Compile(expr)
+}
+
+// splitArgs splits s by sep which is not quoted, and trims the spaces of each part
+func splitArgs(s string, sep byte) []string {
+	var parts []string
+
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// unquote strips the quotes of 'value' and r'regex', isRegex tells if it is a regex
+func unquote(s string) (value string, isRegex bool) {
+	last := len(s) - 1
+	sign := byte('\'')
+	if len(s) >= 3 && s[0] == 'r' && s[1] == sign && s[last] == sign {
+		return s[2:last], true
+	}
+	if len(s) >= 2 && s[0] == sign && s[last] == sign {
+		return s[1:last], false
+	}
+	return s, false
+}
+
+////////// Apply rules
+
+func (c *ConfigReader) validateValueOfField(fieldKey string, structField reflect.StructField, structRef reflect.Value) error {
+	// If there is no value, ignore the validation check
+	// If the value is required, it should use the require check
+	raw := c.viper.Get(fieldKey)
+	if raw == nil || c.unresolvedKeys[strings.ToLower(fieldKey)] {
+		return nil
+	}
+
+	validation := structField.Tag.Get(tagValidation)
+	if len(validation) == 0 {
+		return nil
+	}
+
+	expr, err := parseValidation(validation)
+	if err != nil {
+		return fmt.Errorf("[%s] %v", fieldKey, err)
+	}
+
+	val, err := decodeValue(raw, structField.Type)
+	if err != nil {
+		if c.isSecretField(fieldKey, structField) {
+			return fmt.Errorf("[%s] failed to resolve value for validation [%s]", fieldKey, validation)
+		}
+		return fmt.Errorf("[%s] failed to resolve value for validation [%s]: %v", fieldKey, validation, err)
+	}
+
+	// Record the failed rule of every group to tell which one is not passed
+	failed := make([]string, 0, len(expr))
+	var cause error
+	for _, group := range expr {
+		rule, failure, err := c.checkRuleGroup(fieldKey, structField.Type, val, group)
+		if err != nil {
+			return fmt.Errorf("[%s] failed to parse validation values [%s]: %v", fieldKey, rule.raw, err)
+		}
+		if failure == nil {
+			return nil
+		}
+
+		failed = append(failed, rule.raw)
+		if cause == nil && failure != errNotPassed {
+			cause = failure
+		}
+	}
+
+	want := strings.Join(failed, " | ")
+	if c.isSecretField(fieldKey, structField) {
+		// the causes may tell the elements of the value
+		return fmt.Errorf("[%s] did not pass validation [%s]. real [%v]", fieldKey, want, maskValue(raw))
+	}
+	if cause != nil {
+		return xerrors.Errorf("[%s] did not pass validation [%s]. real [%v]: %w", fieldKey, want, raw, cause)
+	}
+	return fmt.Errorf("[%s] did not pass validation [%s]. real [%v]", fieldKey, want, raw)
+}
+
+// checkRuleGroup returns the first rule of the group which is not passed or can not be applied
+func (c *ConfigReader) checkRuleGroup(fieldKey string, typ reflect.Type, val interface{}, group []*validationRule) (*validationRule, error, error) {
+	for _, rule := range group {
+		failure, err := c.checkRule(fieldKey, typ, val, rule)
+		if failure != nil || err != nil {
+			return rule, failure, err
+		}
+	}
+	return nil, nil, nil
+}
+
+// checkRule returns a failure if the value does not pass the rule,
+// and an error if the rule can not be applied to the value
+func (c *ConfigReader) checkRule(fieldKey string, typ reflect.Type, val interface{}, rule *validationRule) (failure error, err error) {
+	ref := reflect.ValueOf(val)
+
+	switch rule.action {
+	case actionIn:
+		return notPassed(checkIn(ref, rule.items))
+	case actionRange:
+		// range of string means the range of the length
+		if ref.Kind() == reflect.String {
+			ref = reflect.ValueOf(int64(ref.Len()))
+		}
+		return notPassed(checkRange(ref, rule.rng))
+	case actionRegex:
+		if ref.Kind() != reflect.String {
+			return nil, fmt.Errorf("regex does not apply to type [%s]", typ)
+		}
+		return notPassed(rule.regex.MatchString(ref.String()), nil)
+	case actionLen:
+		switch ref.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			return notPassed(checkRange(reflect.ValueOf(int64(ref.Len())), rule.rng))
+		}
+		return nil, fmt.Errorf("len does not apply to type [%s]", typ)
+	case actionEach:
+		return c.checkElements(fieldKey, typ, ref, rule.elem)
+	case actionKeys:
+		if ref.Kind() != reflect.Map {
+			return nil, fmt.Errorf("keys does not apply to type [%s]", typ)
+		}
+		for _, key := range sortedMapKeys(ref) {
+			failure, err := c.checkRule(fieldKey, typ.Key(), key.Interface(), rule.elem)
+			if failure != nil || err != nil {
+				return elementFailure("key", key, failure), err
+			}
+		}
+		return nil, nil
+	}
+
+	validator, ok := c.validators[rule.action]
+	if !ok {
+		return nil, fmt.Errorf("unsupported action [%s]", rule.action)
+	}
+	if rule.args != "" {
+		return nil, fmt.Errorf("validator [%s] does not accept args", rule.action)
+	}
+	return validator(fieldKey, typ, val), nil
+}
+
+// checkElements checks every element of a slice, an array or values of a map
+func (c *ConfigReader) checkElements(fieldKey string, typ reflect.Type, ref reflect.Value, rule *validationRule) (failure error, err error) {
+	switch ref.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < ref.Len(); i++ {
+			elem := ref.Index(i)
+			failure, err := c.checkRule(fieldKey, typ.Elem(), elem.Interface(), rule)
+			if failure != nil || err != nil {
+				return elementFailure(fmt.Sprintf("element [%d]", i), elem, failure), err
+			}
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(ref) {
+			elem := ref.MapIndex(key)
+			failure, err := c.checkRule(fieldKey, typ.Elem(), elem.Interface(), rule)
+			if failure != nil || err != nil {
+				return elementFailure(fmt.Sprintf("element [%v]", key), elem, failure), err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("each does not apply to type [%s]", typ)
+	}
+
+	return nil, nil
+}
+
+// elementFailure tells which element of the collection is not passed
+func elementFailure(name string, elem reflect.Value, failure error) error {
+	switch {
+	case failure == nil:
+		return nil
+	case failure == errNotPassed:
+		return fmt.Errorf("%s is [%v]", name, elem)
+	}
+	return xerrors.Errorf("%s: %w", name, failure)
+}
+
+// sortedMapKeys returns the keys of the map in a stable order
+func sortedMapKeys(ref reflect.Value) []reflect.Value {
+	keys := ref.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
+
+func notPassed(passed bool, err error) (error, error) {
+	if err != nil {
+		return nil, err
+	}
+	if !passed {
+		return errNotPassed, nil
+	}
+	return nil, nil
+}
+
+func checkIn(ref reflect.Value, items []ruleItem) (bool, error) {
+	for _, item := range items {
+		if ref.Kind() == reflect.String {
+			if ref.String() == item.value || (item.regex != nil && item.regex.MatchString(ref.String())) {
+				return true, nil
+			}
+			continue
+		}
+
+		if item.regex != nil {
+			return false, fmt.Errorf("regex does not apply to type [%s]", ref.Type())
+		}
+		cmp, err := compareValue(ref, item.value)
+		if err != nil {
+			return false, err
+		}
+		if cmp == 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func checkRange(ref reflect.Value, rng *rangeRule) (bool, error) {
+	if rng.lAct != rangeInf {
+		cmp, err := compareValue(ref, rng.lVal)
+		if err != nil {
+			return false, err
+		}
+		if (rng.lAct == ">=" && cmp < 0) || (rng.lAct == ">" && cmp <= 0) {
+			return false, nil
+		}
+	}
+
+	if rng.rAct != rangeInf {
+		cmp, err := compareValue(ref, rng.rVal)
+		if err != nil {
+			return false, err
+		}
+		if (rng.rAct == "<=" && cmp > 0) || (rng.rAct == "<" && cmp >= 0) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// compareValue parses s into the type of ref, and returns -1, 0 or 1
+// if the value of ref is less than, equal to or greater than it
+func compareValue(ref reflect.Value, s string) (int, error) {
+	if ref.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		return compareInt64(ref.Int(), int64(d)), nil
+	}
+
+	switch ref.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return compareInt64(ref.Int(), v), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case ref.Uint() < v:
+			return -1, nil
+		case ref.Uint() > v:
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, err
+		}
+		diff := ref.Float() - v
+		switch {
+		case math.Abs(diff) < 1e-3:
+			return 0, nil
+		case diff < 0:
+			return -1, nil
+		}
+		return 1, nil
+	}
+
+	return 0, fmt.Errorf("unsupported type [%s]", ref.Type())
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package configreader
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseValidation(t *testing.T) {
+	expr, err := parseValidation("range:[1:64];regex:'^[a-z|;]+$' | in:[a, r'b.*', 'c d']")
+	assert.Nil(t, err)
+	assert.Len(t, expr, 2)
+	assert.Len(t, expr[0], 2)
+	assert.Len(t, expr[1], 1)
+
+	assert.Equal(t, "range", expr[0][0].action)
+	assert.Equal(t, &rangeRule{lAct: ">=", rAct: "<=", lVal: "1", rVal: "64"}, expr[0][0].rng)
+	assert.Equal(t, "regex:'^[a-z|;]+$'", expr[0][1].raw)
+	assert.True(t, expr[0][1].regex.MatchString("a|b"))
+
+	items := expr[1][0].items
+	assert.Len(t, items, 3)
+	assert.Equal(t, "a", items[0].value)
+	assert.NotNil(t, items[1].regex)
+	assert.Equal(t, "c d", items[2].value)
+
+	expr, err = parseValidation("range:(, 10]")
+	assert.Nil(t, err)
+	assert.Equal(t, rangeInf, expr[0][0].rng.lAct)
+	assert.Equal(t, "<=", expr[0][0].rng.rAct)
+
+	badRules := map[string]string{
+		"in:[a, b":         "unclosed bracket",
+		"regex:'^a":        "unterminated quote",
+		"in:[a]]":          "unexpected ']'",
+		"in:[a];":          "empty rule",
+		"range:[1, 2, 3]":  "want a range",
+		"range:[,]":        "want a range",
+		"in:a, b":          "want a list",
+		"regex:'[a-'":      "regex",
+		":[1, 2]":          "missing action",
+		"in:[1] || in:[2]": "empty rule",
+	}
+	for rule, msg := range badRules {
+		_, err := parseValidation(rule)
+		assert.NotNil(t, err, rule)
+		if err != nil {
+			assert.Contains(t, err.Error(), msg, rule)
+		}
+	}
+}
+
+func TestValidationChained(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	configData := []byte(`{
+		"name": "abc",
+		"mode": "7",
+		"badname": "ABC",
+		"badmode": "70"
+	}`)
+	err := writeFile(fs, "/tmp/config.json", configData)
+	assert.Nil(t, err)
+
+	type Conf struct {
+		Name    string `validation:"range:[1:64];regex:'^[a-z]+$'"`
+		Mode    int    `validation:"in:[1, 2] | range:[5, 9]"`
+		BadName string `validation:"range:[1:64];regex:'^[a-z]+$'"`
+		BadMode int    `validation:"in:[1, 2] | range:[5, 9]"`
+	}
+
+	SetConfigName("config")
+	AddConfigPath("/tmp")
+
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.NotNil(t, err)
+
+	var verrs ValidationErrors
+	assert.ErrorAs(t, err, &verrs)
+	assert.Len(t, verrs, 2)
+	assert.Equal(t, "[badname] did not pass validation [regex:'^[a-z]+$']. real [ABC]", verrs[0].Error())
+	assert.Equal(t, "[badmode] did not pass validation [in:[1, 2] | range:[5, 9]]. real [70]", verrs[1].Error())
+}
+
+func TestValidationCollections(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	configData := []byte(`{
+		"origins": ["a.com", "b.com"],
+		"brokers": ["kafka:9092", "zk:2181"],
+		"ports": {"http": 80, "admin": 8080},
+		"empty": [],
+		"badorigins": ["a.com", "c.org"],
+		"badports": {"http": 80, "Admin!": 8080},
+		"badweights": {"a": 1, "b": 200}
+	}`)
+	err := writeFile(fs, "/tmp/config.json", configData)
+	assert.Nil(t, err)
+
+	type Conf struct {
+		Origins    []string       `validation:"len:[1:10];each:regex:'\\.com$'"`
+		Brokers    []string       `validation:"each:in:[r'^kafka:', r'^zk:']"`
+		Ports      map[string]int `validation:"len:[1, 3];keys:regex:'^[a-z]+$';each:range:[1, 65535]"`
+		Empty      []int          `validation:"len:[1, 3]"`
+		BadOrigins []string       `validation:"each:regex:'\\.com$'"`
+		BadPorts   map[string]int `validation:"keys:regex:'^[a-z]+$'"`
+		BadWeights map[string]int `validation:"each:range:[0, 100]"`
+	}
+
+	SetConfigName("config")
+	AddConfigPath("/tmp")
+
+	conf := Conf{}
+	err = LoadConfig(&conf)
+	assert.NotNil(t, err)
+
+	var verrs ValidationErrors
+	assert.ErrorAs(t, err, &verrs)
+	assert.Len(t, verrs, 4)
+	assert.Equal(t, "empty", verrs[0].Key)
+	assert.Contains(t, verrs[0].Error(), "[len:[1, 3]]")
+	assert.Equal(t, "badorigins", verrs[1].Key)
+	assert.Contains(t, verrs[1].Error(), "element [1] is [c.org]")
+	assert.Equal(t, "badports", verrs[2].Key)
+	assert.Contains(t, verrs[2].Error(), "key is [admin!]")
+	assert.Equal(t, "badweights", verrs[3].Key)
+	assert.Contains(t, verrs[3].Error(), "element [b] is [200]")
+}
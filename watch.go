@@ -0,0 +1,287 @@
+package configreader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+)
+
+// watchDebounce is how long to wait for the events to settle down before reloading,
+// editors usually write a file in several steps
+const watchDebounce = 100 * time.Millisecond
+
+// SetWatchInterval wraps the global ConfigReader instance
+func SetWatchInterval(interval time.Duration) { c.SetWatchInterval(interval) }
+
+// SetWatchInterval sets the interval of polling the config files,
+// it's used when the filesystem is not the OS one, e.g. afero.MemMapFs
+func (c *ConfigReader) SetWatchInterval(interval time.Duration) {
+	if interval > 0 {
+		c.watchInterval = interval
+	}
+}
+
+// OnReloadError wraps the global ConfigReader instance
+func OnReloadError(fn func(error)) { c.OnReloadError(fn) }
+
+// OnReloadError sets the callback of the failed reloads when watching the config files,
+// the live config is kept as it is
+func (c *ConfigReader) OnReloadError(fn func(error)) {
+	c.onReloadError = fn
+}
+
+// Watch wraps the global ConfigReader instance
+func Watch(confPtr interface{}, onChange func(old, new interface{})) (*Watcher, error) {
+	return c.Watch(confPtr, onChange)
+}
+
+// Watch loads configs into confPtr and then watches the config files loaded.
+// Once the files change, the configs are reloaded into a fresh struct, which replaces
+// the live one only if it passes the validation, and onChange is called with the pointers
+// of the old and new structs. confPtr itself is never changed after Watch returns,
+// use Watcher.Current to get the live config.
+func (c *ConfigReader) Watch(confPtr interface{}, onChange func(old, new interface{})) (*Watcher, error) {
+	err := c.loadConfig(confPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		reader:   c,
+		typ:      reflect.TypeOf(confPtr).Elem(),
+		onChange: onChange,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		stats:    make(map[string]fileStat),
+	}
+	w.current.Store(confPtr)
+
+	if _, ok := c.fs.(*afero.OsFs); ok {
+		w.notify, err = fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = w.watchFiles()
+	if err != nil {
+		if w.notify != nil {
+			w.notify.Close()
+		}
+		return nil, err
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Watcher watches the config files and holds the live config
+type Watcher struct {
+	// reader loaded the live config, it's replaced on every successful reload
+	reader   *ConfigReader
+	typ      reflect.Type
+	current  atomic.Value
+	onChange func(old, new interface{})
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// the files being watched, by fsnotify or by polling
+	files  []string
+	notify *fsnotify.Watcher
+	stats  map[string]fileStat
+}
+
+type fileStat struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// Current returns the pointer to the live config struct, it's safe for concurrent use
+func (w *Watcher) Current() interface{} {
+	return w.current.Load()
+}
+
+// Stop stops watching the config files
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+		if w.notify != nil {
+			w.notify.Close()
+		}
+	})
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	var (
+		events <-chan fsnotify.Event
+		errs   <-chan error
+		tick   <-chan time.Time
+	)
+	if w.notify != nil {
+		events, errs = w.notify.Events, w.notify.Errors
+	} else {
+		ticker := time.NewTicker(w.reader.watchInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var timer *time.Timer
+	var fire <-chan time.Time
+	debounce := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.NewTimer(watchDebounce)
+		fire = timer.C
+	}
+
+	for {
+		select {
+		case <-w.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case event := <-events:
+			if w.isWatched(event.Name) {
+				debounce()
+			}
+		case err := <-errs:
+			w.reportError(err)
+		case <-tick:
+			if w.poll() {
+				debounce()
+			}
+		case <-fire:
+			fire = nil
+			w.reload()
+		}
+	}
+}
+
+// reload runs the whole loading pipeline with a fresh reader and a fresh struct
+func (w *Watcher) reload() {
+	r := w.reader.clone()
+	confPtr := reflect.New(w.typ).Interface()
+
+	err := r.loadConfig(confPtr)
+	if err != nil {
+		w.reportError(err)
+		return
+	}
+
+	w.reader = r
+	if err := w.watchFiles(); err != nil {
+		w.reportError(err)
+	}
+
+	old := w.current.Load()
+	if reflect.DeepEqual(old, confPtr) {
+		return
+	}
+	w.current.Store(confPtr)
+
+	if w.onChange != nil {
+		w.onChange(old, confPtr)
+	}
+}
+
+func (w *Watcher) reportError(err error) {
+	if w.reader.onReloadError != nil {
+		w.reader.onReloadError(err)
+	}
+}
+
+// watchFiles watches the config files merged by the reader
+func (w *Watcher) watchFiles() error {
+	w.files = w.files[:0]
+	for _, source := range w.reader.sources {
+		if source.name == "" {
+			continue
+		}
+		filename := filepath.Clean(source.name)
+		w.files = append(w.files, filename)
+
+		if w.notify != nil {
+			// watch the folder, as editors may replace the file by renaming
+			err := w.notify.Add(filepath.Dir(filename))
+			if err != nil {
+				return err
+			}
+		} else if _, ok := w.stats[filename]; !ok {
+			w.stats[filename] = w.stat(filename)
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) isWatched(filename string) bool {
+	filename = filepath.Clean(filename)
+	for _, file := range w.files {
+		if file == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// poll tells whether any of the files is changed since last time
+func (w *Watcher) poll() bool {
+	changed := false
+	for _, filename := range w.files {
+		stat := w.stat(filename)
+		if stat != w.stats[filename] {
+			w.stats[filename] = stat
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (w *Watcher) stat(filename string) fileStat {
+	info, err := w.reader.fs.Stat(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileStat{}
+		}
+		w.reportError(err)
+		return w.stats[filename]
+	}
+	return fileStat{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// clone creates a reader with the same settings, without the state of loading
+func (c *ConfigReader) clone() *ConfigReader {
+	r := New()
+	r.SetFs(c.fs)
+	r.SetEnvPrefix(c.envPrefix)
+	r.configName = c.configName
+	r.configPaths = append([]string(nil), c.configPaths...)
+	r.fileEnvName = c.fileEnvName
+	r.configEnv = c.configEnv
+	r.allowMerge = c.allowMerge
+	r.flagset = c.flagset
+	r.ownFlagSet = c.ownFlagSet
+	r.autoParseFlags = c.autoParseFlags
+	r.strict = c.strict
+	r.watchInterval = c.watchInterval
+	r.onReloadError = c.onReloadError
+	r.decryptionKey = c.decryptionKey
+	r.decryptionKeyFile = c.decryptionKeyFile
+	for name, fn := range c.validators {
+		r.validators[name] = fn
+	}
+	return r
+}
@@ -0,0 +1,114 @@
+package configreader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch(t *testing.T) {
+	defer testTearDown()
+	fs := afero.NewMemMapFs()
+
+	SetFs(fs)
+
+	err := writeFile(fs, "/tmp/config.yaml", []byte(`host: base.local
+port: 80
+`))
+	assert.Nil(t, err)
+	err = writeFile(fs, "/tmp/config_dev.yaml", []byte(`host: dev.local`))
+	assert.Nil(t, err)
+
+	type Conf struct {
+		Host string
+		Port int `validation:"range:[1,65535]"`
+	}
+
+	changes := make(chan [2]*Conf, 1)
+	reloadErrs := make(chan error, 1)
+
+	AddConfigPath("/tmp")
+	SetWatchInterval(10 * time.Millisecond)
+	OnReloadError(func(err error) { reloadErrs <- err })
+
+	conf := Conf{}
+	w, err := Watch(&conf, func(old, new interface{}) {
+		changes <- [2]*Conf{old.(*Conf), new.(*Conf)}
+	})
+	assert.Nil(t, err)
+	defer w.Stop()
+	assert.Equal(t, &Conf{Host: "dev.local", Port: 80}, w.Current())
+
+	// the overlay is watched as well
+	err = writeFile(fs, "/tmp/config_dev.yaml", []byte(`host: dev2.local`))
+	assert.Nil(t, err)
+	select {
+	case change := <-changes:
+		assert.Equal(t, &Conf{Host: "dev.local", Port: 80}, change[0])
+		assert.Equal(t, &Conf{Host: "dev2.local", Port: 80}, change[1])
+	case <-time.After(time.Second):
+		t.Fatal("config is not reloaded")
+	}
+	assert.Equal(t, &Conf{Host: "dev2.local", Port: 80}, w.Current())
+	assert.Equal(t, Conf{Host: "dev.local", Port: 80}, conf)
+
+	// invalid configs are not swapped in
+	err = writeFile(fs, "/tmp/config.yaml", []byte(`port: 0`))
+	assert.Nil(t, err)
+	select {
+	case err := <-reloadErrs:
+		assert.EqualError(t, err, "[port] did not pass validation [range:[1,65535]]. real [0]")
+	case <-changes:
+		t.Fatal("invalid config is swapped in")
+	case <-time.After(time.Second):
+		t.Fatal("config is not reloaded")
+	}
+	assert.Equal(t, &Conf{Host: "dev2.local", Port: 80}, w.Current())
+
+	w.Stop()
+	err = writeFile(fs, "/tmp/config.yaml", []byte(`port: 81`))
+	assert.Nil(t, err)
+	select {
+	case <-changes:
+		t.Fatal("config is reloaded after stopped")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatchOsFs(t *testing.T) {
+	defer testTearDown()
+
+	dir, err := ioutil.TempDir("", "configreader")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(filename, []byte(`{"host": "base.local"}`), 0644)
+	assert.Nil(t, err)
+
+	type Conf struct {
+		Host string
+	}
+
+	changes := make(chan *Conf, 1)
+
+	conf := Conf{}
+	SetConfigFile(filename)
+	w, err := Watch(&conf, func(old, new interface{}) { changes <- new.(*Conf) })
+	assert.Nil(t, err)
+	defer w.Stop()
+
+	err = ioutil.WriteFile(filename, []byte(`{"host": "new.local"}`), 0644)
+	assert.Nil(t, err)
+	select {
+	case change := <-changes:
+		assert.Equal(t, &Conf{Host: "new.local"}, change)
+	case <-time.After(time.Second):
+		t.Fatal("config is not reloaded")
+	}
+}